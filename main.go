@@ -1,37 +1,48 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	docopt "github.com/docopt/docopt-go"
 	"github.com/ghodss/yaml"
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/cpu"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/cpuid"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/fake"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/iommu"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/kernel"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/local"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/memory"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/network"
-	os_features "github.com/kubernetes-incubator/node-feature-discovery/source/os"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/panic_fake"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/pci"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/pstate"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/rapl"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/rdt"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/selinux"
-	"github.com/kubernetes-incubator/node-feature-discovery/source/storage"
 	api "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	k8sclient "k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+
+	// Feature sources register themselves on import
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/bpf"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/cgroup"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/cpu"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/cpuid"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/external"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/iommu"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/kernel"
+	"github.com/kubernetes-incubator/node-feature-discovery/source/local"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/memory"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/network"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/os"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/pci"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/pstate"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/rapl"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/rdt"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/security"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/selinux"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/storage"
+	_ "github.com/kubernetes-incubator/node-feature-discovery/source/syscalls"
 )
 
 const (
@@ -57,19 +68,22 @@ var (
 	stderrLogger = log.New(os.Stderr, "", log.LstdFlags)
 )
 
-// Global config
+// Global config. The config of each source is stored as raw data and decoded
+// into the config struct of the corresponding registered source.
 type NFDConfig struct {
-	Sources struct {
-		Kernel *kernel.NFDConfig `json:"kernel,omitempty"`
-		Pci    *pci.NFDConfig    `json:"pci,omitempty"`
-	} `json:"sources,omitempty"`
+	Sources map[string]json.RawMessage `json:"sources,omitempty"`
 }
 
-var config = NFDConfig{}
-
 // Labels are a Kubernetes representation of discovered features.
 type Labels map[string]string
 
+// Annotations are node annotations requested by feature sources.
+type Annotations map[string]string
+
+// ExtendedResources are node extended resources requested by feature
+// sources, mapping resource names to their capacities.
+type ExtendedResources map[string]int64
+
 // APIHelpers represents a set of API helpers for Kubernetes
 type APIHelpers interface {
 	// GetClient returns a client
@@ -88,19 +102,37 @@ type APIHelpers interface {
 	// API server using the client library.
 	AddLabels(*api.Node, Labels)
 
+	// RemoveAnnotations removes annotations from the supplied node that
+	// contain the search string provided. In order to publish the changes,
+	// the node must subsequently be updated via the API server using the
+	// client library.
+	RemoveAnnotations(*api.Node, string)
+
+	// AddAnnotations modifies the supplied node's annotations collection.
+	// In order to publish the annotations, the node must be subsequently
+	// updated via the API server using the client library.
+	AddAnnotations(*api.Node, Annotations)
+
 	// UpdateNode updates the node via the API server using a client.
 	UpdateNode(*k8sclient.Clientset, *api.Node) error
+
+	// UpdateExtendedResources replaces the extended resources of the node
+	// that contain the search string provided with the supplied ones, via
+	// the API server using a client.
+	UpdateExtendedResources(*k8sclient.Clientset, *api.Node, string, ExtendedResources) error
 }
 
 // Command line arguments
 type Args struct {
-	labelWhiteList string
-	configFile     string
-	noPublish      bool
-	options        string
-	oneshot        bool
-	sleepInterval  time.Duration
-	sources        []string
+	labelWhiteList        string
+	configFile            string
+	explain               bool
+	generateHookAllowlist bool
+	noPublish             bool
+	options               string
+	oneshot               bool
+	sleepInterval         time.Duration
+	sources               []string
 }
 
 func main() {
@@ -112,12 +144,27 @@ func main() {
 	// Parse command-line arguments.
 	args := argsParse(nil)
 
+	if args.explain {
+		source.SetExplainOutput(os.Stdout)
+	}
+
+	source.NFDVersion = version
+	source.NodeName = os.Getenv(NodeNameEnv)
+
 	// Parse config
 	err := configParse(args.configFile, args.options)
 	if err != nil {
 		stderrLogger.Print(err)
 	}
 
+	if args.generateHookAllowlist {
+		err = printHookAllowlist()
+		if err != nil {
+			stderrLogger.Fatalf("failed to generate hook allowlist: %s", err.Error())
+		}
+		return
+	}
+
 	// Configure the parameters for feature discovery.
 	enabledSources, labelWhiteList, err := configureParameters(args.sources, args.labelWhiteList)
 	if err != nil {
@@ -128,10 +175,16 @@ func main() {
 
 	for {
 		// Get the set of feature labels.
-		labels := createFeatureLabels(enabledSources, labelWhiteList)
+		labels, annotations, resources := createFeatureLabels(enabledSources, labelWhiteList)
+
+		// Explain only the first round, in order not to flood the output
+		// when re-labeling periodically
+		if args.explain {
+			source.SetExplainOutput(nil)
+		}
 
 		// Update the node with the feature labels.
-		err = updateNodeWithFeatureLabels(helper, args.noPublish, labels)
+		err = updateNodeWithFeatureLabels(helper, args.noPublish, labels, annotations, resources)
 		if err != nil {
 			stderrLogger.Fatalf("error occurred while updating node with feature labels: %s", err.Error())
 		}
@@ -157,7 +210,8 @@ func argsParse(argv []string) (args Args) {
   Usage:
   %s [--no-publish] [--sources=<sources>] [--label-whitelist=<pattern>]
      [--oneshot | --sleep-interval=<seconds>] [--config=<path>]
-     [--options=<config>]
+     [--options=<config>] [--explain]
+  %s --generate-hook-allowlist [--config=<path>] [--options=<config>]
   %s -h | --help
   %s --version
 
@@ -172,19 +226,32 @@ func argsParse(argv []string) (args Args) {
                               will override settings read from the config file.
                               [Default: ]
   --sources=<sources>         Comma separated list of feature sources.
-                              [Default: cpu,cpuid,iommu,kernel,local,memory,network,os,pci,pstate,rapl,rdt,selinux,storage]
+                              [Default: %s]
   --no-publish                Do not publish discovered features to the
                               cluster-local Kubernetes API server.
   --label-whitelist=<pattern> Regular expression to filter label names to
                               publish to the Kubernetes API server. [Default: ]
   --oneshot                   Label once and exit.
+  --explain                   Print the evidence behind discovered features,
+                              i.e. the data each source used and the decisions
+                              taken, and the reasons for not publishing labels.
+                              Only the first labeling round is explained.
   --sleep-interval=<seconds>  Time to sleep between re-labeling. Non-positive
                               value implies no re-labeling (i.e. infinite
-                              sleep). [Default: 60s]`,
+                              sleep). [Default: 60s]
+  --generate-hook-allowlist   Print a hookAllowlist config of the local source
+                              that permits the current versions of all hooks
+                              in the configured hook directories, and exit.
+
+  Feature sources:
+%s`,
+		ProgramName,
 		ProgramName,
 		ProgramName,
 		ProgramName,
 		ProgramName,
+		strings.Join(source.DefaultSources(), ","),
+		sourcesHelp(),
 	)
 
 	arguments, _ := docopt.Parse(usage, argv, true,
@@ -193,6 +260,8 @@ func argsParse(argv []string) (args Args) {
 	// Parse argument values as usable types.
 	var err error
 	args.configFile = arguments["--config"].(string)
+	args.explain = arguments["--explain"].(bool)
+	args.generateHookAllowlist = arguments["--generate-hook-allowlist"].(bool)
 	args.noPublish = arguments["--no-publish"].(bool)
 	args.options = arguments["--options"].(string)
 	args.sources = strings.Split(arguments["--sources"].(string), ",")
@@ -212,28 +281,116 @@ func argsParse(argv []string) (args Args) {
 	return args
 }
 
-// Parse configuration options
-func configParse(filepath string, overrides string) error {
-	config.Sources.Kernel = &kernel.Config
-	config.Sources.Pci = &pci.Config
+// sourcesHelp returns a description of all registered feature sources, for
+// the help output.
+func sourcesHelp() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, r := range source.Registrations() {
+		desc := r.Description
+		if !r.Supported() {
+			desc += fmt.Sprintf(" (%s)", r.ArchsDescription())
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", r.Name(), desc)
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
 
-	data, err := ioutil.ReadFile(filepath)
+// printHookAllowlist prints, in the config file format, an allowlist that
+// permits the current versions of all hooks of the local source.
+func printHookAllowlist() error {
+	r, ok := source.Lookup("local")
+	if !ok {
+		return fmt.Errorf("local source not available")
+	}
+	config := r.Source.(source.ConfigurableSource).GetConfig().(*local.NFDConfig)
+	allowlist, err := local.HookAllowlist(config)
 	if err != nil {
-		return fmt.Errorf("Failed to read config file: %s", err)
+		return err
 	}
-
-	// Read config file
-	err = yaml.Unmarshal(data, &config)
+	data, err := yaml.Marshal(map[string]interface{}{
+		"sources": map[string]interface{}{
+			"local": map[string]interface{}{"hookAllowlist": allowlist},
+		},
+	})
 	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// Parse configuration options. The config of each configurable source is
+// built from its defaults, overridden by the config file which, in turn, is
+// overridden by the --options. Source configs are only updated if all of
+// them are valid.
+func configParse(filepath string, overrides string) error {
+	// Read config file. Overrides are applied even if it is not available.
+	fileConfig := NFDConfig{}
+	data, readErr := ioutil.ReadFile(filepath)
+	if readErr != nil {
+		readErr = fmt.Errorf("Failed to read config file: %s", readErr)
+	} else if err := yaml.Unmarshal(data, &fileConfig); err != nil {
 		return fmt.Errorf("Failed to parse config file: %s", err)
 	}
 
 	// Parse config overrides
-	err = yaml.Unmarshal([]byte(overrides), &config)
-	if err != nil {
+	overrideConfig := NFDConfig{}
+	if err := yaml.Unmarshal([]byte(overrides), &overrideConfig); err != nil {
 		return fmt.Errorf("Failed to parse --options: %s", err)
 	}
 
+	for _, c := range []NFDConfig{fileConfig, overrideConfig} {
+		for name := range c.Sources {
+			r, ok := source.Lookup(name)
+			if !ok {
+				stderrLogger.Printf("WARNING: config for unknown source '%s', ignoring...", name)
+			} else if _, ok := r.Source.(source.ConfigurableSource); !ok {
+				stderrLogger.Printf("WARNING: source '%s' does not have any config options, ignoring...", name)
+			}
+		}
+	}
+
+	// Decode and apply the config of each configurable source. A source
+	// whose config is invalid keeps its previous config.
+	configErrs := []string{}
+	for _, r := range source.Registrations() {
+		s, ok := r.Source.(source.ConfigurableSource)
+		if !ok {
+			continue
+		}
+		if err := sourceConfigParse(r.Name(), s, fileConfig, overrideConfig); err != nil {
+			configErrs = append(configErrs, err.Error())
+		}
+	}
+
+	if len(configErrs) > 0 {
+		if readErr != nil {
+			configErrs = append([]string{readErr.Error()}, configErrs...)
+		}
+		return fmt.Errorf("%s", strings.Join(configErrs, "; "))
+	}
+	return readErr
+}
+
+// sourceConfigParse decodes the config of one source from the config file
+// and the overrides, on top of the defaults, and applies it if it is valid
+func sourceConfigParse(name string, s source.ConfigurableSource, configs ...NFDConfig) error {
+	conf := s.NewConfig()
+	for _, c := range configs {
+		if raw, ok := c.Sources[name]; ok {
+			if err := json.Unmarshal(raw, conf); err != nil {
+				return fmt.Errorf("Invalid config for source '%s', keeping the previous config: %s", name, err)
+			}
+		}
+	}
+	if v, ok := conf.(source.ConfigValidator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("Invalid config for source '%s', keeping the previous config: %s", name, err)
+		}
+	}
+	s.SetConfig(conf)
 	return nil
 }
 
@@ -243,34 +400,28 @@ func configureParameters(sourcesWhiteList []string, labelWhiteListStr string) (e
 	// A map for lookup
 	sourcesWhiteListMap := map[string]struct{}{}
 	for _, s := range sourcesWhiteList {
-		sourcesWhiteListMap[strings.TrimSpace(s)] = struct{}{}
+		name := strings.TrimSpace(s)
+		if name == "" {
+			continue
+		}
+		if _, ok := source.Lookup(name); !ok {
+			stderrLogger.Printf("WARNING: unknown feature source '%s', ignoring...", name)
+			continue
+		}
+		sourcesWhiteListMap[name] = struct{}{}
 	}
 
 	// Configure feature sources.
-	allSources := []source.FeatureSource{
-		cpu.Source{},
-		cpuid.Source{},
-		fake.Source{},
-		iommu.Source{},
-		kernel.Source{},
-		local.Source{},
-		memory.Source{},
-		network.Source{},
-		os_features.Source{},
-		panic_fake.Source{},
-		pci.Source{},
-		pstate.Source{},
-		rapl.Source{},
-		rdt.Source{},
-		selinux.Source{},
-		storage.Source{},
-	}
-
 	enabledSources = []source.FeatureSource{}
-	for _, s := range allSources {
-		if _, enabled := sourcesWhiteListMap[s.Name()]; enabled {
-			enabledSources = append(enabledSources, s)
+	for _, r := range source.Registrations() {
+		if _, enabled := sourcesWhiteListMap[r.Name()]; !enabled {
+			continue
 		}
+		if !r.Supported() {
+			stderrLogger.Printf("WARNING: feature source '%s' is not supported on this architecture, ignoring...", r.Name())
+			continue
+		}
+		enabledSources = append(enabledSources, r.Source)
 	}
 
 	// Compile labelWhiteList regex
@@ -284,9 +435,12 @@ func configureParameters(sourcesWhiteList []string, labelWhiteListStr string) (e
 }
 
 // createFeatureLabels returns the set of feature labels from the enabled
-// sources and the whitelist argument.
-func createFeatureLabels(sources []source.FeatureSource, labelWhiteList *regexp.Regexp) (labels Labels) {
+// sources and the whitelist argument, together with the annotations and
+// extended resources requested by the sources.
+func createFeatureLabels(sources []source.FeatureSource, labelWhiteList *regexp.Regexp) (labels Labels, annotations Annotations, resources ExtendedResources) {
 	labels = Labels{}
+	annotations = Annotations{}
+	resources = ExtendedResources{}
 	// Add the version of this discovery code as a node label
 	versionLabel := fmt.Sprintf("%s/%s.version", Namespace, ProgramName)
 	labels[versionLabel] = version
@@ -294,34 +448,140 @@ func createFeatureLabels(sources []source.FeatureSource, labelWhiteList *regexp.
 	// Log version label.
 	stdoutLogger.Printf("%s = %s", versionLabel, version)
 
-	// Do feature discovery from all configured sources.
-	for _, source := range sources {
-		labelsFromSource, err := getFeatureLabels(source)
+	// Do feature discovery from all configured sources. Sources consuming the
+	// features of other sources go last.
+	discovered := map[string]source.Features{}
+	for _, s := range orderSources(expandSources(sources)) {
+		source.ExplainTitlef("source [%s]:", s.Name())
+		if consumer, ok := s.(source.FeatureConsumer); ok {
+			consumer.SetDiscoveredFeatures(discovered)
+		}
+		labelsFromSource, features, err := getFeatureLabels(s)
 		if err != nil {
-			stderrLogger.Printf("discovery failed for source [%s]: %s", source.Name(), err.Error())
+			source.Explainf("discovery failed: %s", err.Error())
+			stderrLogger.Printf("discovery failed for source [%s]: %s", s.Name(), err.Error())
 			stderrLogger.Printf("continuing ...")
 			continue
 		}
+		discovered[s.Name()] = features
+
+		names := make([]string, 0, len(labelsFromSource))
+		for name := range labelsFromSource {
+			names = append(names, name)
+		}
+		sort.Strings(names)
 
-		for name, value := range labelsFromSource {
+		for _, name := range names {
+			value := labelsFromSource[name]
 			// Log discovered feature.
 			stdoutLogger.Printf("%s = %s", name, value)
 			// Skip if label doesn't match labelWhiteList
 			if !labelWhiteList.Match([]byte(name)) {
+				source.Explainf("label %s = %s: not published, does not match the whitelist (%s)", name, value, labelWhiteList.String())
 				stderrLogger.Printf("%s does not match the whitelist (%s) and will not be published.", name, labelWhiteList.String())
 				continue
 			}
+			source.Explainf("label %s = %s: published", name, value)
 			labels[name] = value
 		}
+
+		if updater, ok := s.(source.NodeUpdater); ok {
+			addNodeUpdates(updater, annotations, resources)
+		}
 	}
-	return labels
+	return labels, annotations, resources
 }
 
-// updateNodeWithFeatureLabels updates the node with the feature labels, unless
-// disabled via --no-publish flag.
-func updateNodeWithFeatureLabels(helper APIHelpers, noPublish bool, labels Labels) error {
+// addNodeUpdates adds the annotations and extended resources requested by
+// the supplied source.
+func addNodeUpdates(src source.NodeUpdater, annotations Annotations, resources ExtendedResources) {
+	updates := src.NodeUpdates()
+	for k, v := range updates.Annotations {
+		if !validFeatureNameRe.MatchString(k) {
+			source.Explainf("annotation '%s': ignored, invalid name (must match %s)", k, validFeatureNameRe.String())
+			stderrLogger.Printf("Invalid annotation name '%s', ignoring...", k)
+			continue
+		}
+		name := fmt.Sprintf("%s-%s-%s", prefix, src.Name(), k)
+		stdoutLogger.Printf("annotation %s = %q", name, v)
+		source.Explainf("annotation %s = %q: published", name, v)
+		annotations[name] = v
+	}
+	for k, v := range updates.ExtendedResources {
+		if !validFeatureNameRe.MatchString(k) {
+			source.Explainf("extended resource '%s': ignored, invalid name (must match %s)", k, validFeatureNameRe.String())
+			stderrLogger.Printf("Invalid extended resource name '%s', ignoring...", k)
+			continue
+		}
+		if v < 0 {
+			source.Explainf("extended resource '%s': ignored, negative capacity %d", k, v)
+			stderrLogger.Printf("Negative capacity of extended resource '%s', ignoring...", k)
+			continue
+		}
+		name := fmt.Sprintf("%s-%s-%s", prefix, src.Name(), k)
+		stdoutLogger.Printf("extended resource %s = %d", name, v)
+		source.Explainf("extended resource %s = %d: published", name, v)
+		resources[name] = v
+	}
+}
+
+// expandSources returns the list of sources to discover features from,
+// replacing each source group with its current members. Members whose name
+// clashes with another source, or with any registered source, are dropped.
+func expandSources(sources []source.FeatureSource) []source.FeatureSource {
+	expanded := []source.FeatureSource{}
+	names := map[string]struct{}{}
+	for _, s := range sources {
+		if _, ok := s.(source.SourceGroup); !ok {
+			expanded = append(expanded, s)
+			names[s.Name()] = struct{}{}
+		}
+	}
+
+	for _, s := range sources {
+		group, ok := s.(source.SourceGroup)
+		if !ok {
+			continue
+		}
+		members, err := group.Sources()
+		if err != nil {
+			stderrLogger.Printf("failed to list members of source group [%s]: %s", s.Name(), err.Error())
+			continue
+		}
+		for _, m := range members {
+			_, registered := source.Lookup(m.Name())
+			if _, exists := names[m.Name()]; exists || registered {
+				stderrLogger.Printf("source [%s] of group [%s] clashes with an existing source name, ignoring...", m.Name(), s.Name())
+				continue
+			}
+			expanded = append(expanded, m)
+			names[m.Name()] = struct{}{}
+		}
+	}
+	return expanded
+}
+
+// orderSources returns the sources in discovery order, i.e. sources that are
+// not feature consumers first, in their original order, followed by feature
+// consumers.
+func orderSources(sources []source.FeatureSource) []source.FeatureSource {
+	ordered := make([]source.FeatureSource, 0, len(sources))
+	consumers := []source.FeatureSource{}
+	for _, s := range sources {
+		if _, ok := s.(source.FeatureConsumer); ok {
+			consumers = append(consumers, s)
+		} else {
+			ordered = append(ordered, s)
+		}
+	}
+	return append(ordered, consumers...)
+}
+
+// updateNodeWithFeatureLabels updates the node with the feature labels,
+// annotations and extended resources, unless disabled via --no-publish flag.
+func updateNodeWithFeatureLabels(helper APIHelpers, noPublish bool, labels Labels, annotations Annotations, resources ExtendedResources) error {
 	if !noPublish {
-		err := advertiseFeatureLabels(helper, labels)
+		err := advertiseFeatureLabels(helper, labels, annotations, resources)
 		if err != nil {
 			stderrLogger.Printf("failed to advertise labels: %s", err.Error())
 			return err
@@ -331,34 +591,42 @@ func updateNodeWithFeatureLabels(helper APIHelpers, noPublish bool, labels Label
 }
 
 // getFeatureLabels returns node labels for features discovered by the
-// supplied source.
-func getFeatureLabels(source source.FeatureSource) (labels Labels, err error) {
+// supplied source, together with the features themselves.
+func getFeatureLabels(src source.FeatureSource) (labels Labels, features source.Features, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			stderrLogger.Printf("panic occurred during discovery of source [%s]: %v", source.Name(), r)
+			stderrLogger.Printf("panic occurred during discovery of source [%s]: %v", src.Name(), r)
 			err = fmt.Errorf("%v", r)
 		}
 	}()
 
 	labels = Labels{}
-	features, err := source.Discover()
+	features, err = src.Discover()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	for k := range features {
-		// Validate label
-		if !validFeatureNameRe.MatchString(k) {
-			stderrLogger.Printf("Invalid feature name '%s', ignoring...", k)
+	for k, v := range features {
+		if v == nil {
+			source.Explainf("feature '%s': ignored, no value", k)
+			stderrLogger.Printf("Feature '%s' has no value, ignoring...", k)
 			continue
 		}
-		labels[fmt.Sprintf("%s-%s-%s", prefix, source.Name(), k)] = fmt.Sprintf("%v", features[k])
+		for name, value := range source.FeatureLabels(k, v) {
+			// Validate label
+			if !validFeatureNameRe.MatchString(name) {
+				source.Explainf("feature '%s' = %s: ignored, invalid feature name (must match %s)", name, value, validFeatureNameRe.String())
+				stderrLogger.Printf("Invalid feature name '%s', ignoring...", name)
+				continue
+			}
+			labels[fmt.Sprintf("%s-%s-%s", prefix, src.Name(), name)] = value
+		}
 	}
-	return labels, nil
+	return labels, features, nil
 }
 
-// advertiseFeatureLabels advertises the feature labels to a Kubernetes node
-// via the API server.
-func advertiseFeatureLabels(helper APIHelpers, labels Labels) error {
+// advertiseFeatureLabels advertises the feature labels, annotations and
+// extended resources to a Kubernetes node via the API server.
+func advertiseFeatureLabels(helper APIHelpers, labels Labels, annotations Annotations, resources ExtendedResources) error {
 	cli, err := helper.GetClient()
 	if err != nil {
 		stderrLogger.Printf("can't get kubernetes client: %s", err.Error())
@@ -376,6 +644,9 @@ func advertiseFeatureLabels(helper APIHelpers, labels Labels) error {
 	helper.RemoveLabels(node, prefix)
 	// Add labels to the node object.
 	helper.AddLabels(node, labels)
+	// Replace annotations with our prefix
+	helper.RemoveAnnotations(node, prefix)
+	helper.AddAnnotations(node, annotations)
 
 	// Send the updated node to the apiserver.
 	err = helper.UpdateNode(cli, node)
@@ -384,6 +655,14 @@ func advertiseFeatureLabels(helper APIHelpers, labels Labels) error {
 		return err
 	}
 
+	// Extended resources are a part of the node status which is updated
+	// separately
+	err = helper.UpdateExtendedResources(cli, node, prefix, resources)
+	if err != nil {
+		stderrLogger.Printf("can't update extended resources: %s", err.Error())
+		return err
+	}
+
 	return nil
 }
 
@@ -434,6 +713,25 @@ func (h k8sHelpers) AddLabels(n *api.Node, labels Labels) {
 	}
 }
 
+// RemoveAnnotations searches through all annotations on Node n and removes
+// any where the key contain the search string.
+func (h k8sHelpers) RemoveAnnotations(n *api.Node, search string) {
+	for k := range n.Annotations {
+		if strings.Contains(k, search) {
+			delete(n.Annotations, k)
+		}
+	}
+}
+
+func (h k8sHelpers) AddAnnotations(n *api.Node, annotations Annotations) {
+	if n.Annotations == nil && len(annotations) > 0 {
+		n.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		n.Annotations[k] = v
+	}
+}
+
 func (h k8sHelpers) UpdateNode(c *k8sclient.Clientset, n *api.Node) error {
 	// Send the updated node to the apiserver.
 	_, err := c.Core().Nodes().Update(n)
@@ -443,3 +741,60 @@ func (h k8sHelpers) UpdateNode(c *k8sclient.Clientset, n *api.Node) error {
 
 	return nil
 }
+
+// UpdateExtendedResources patches the capacity in the status of Node n. The
+// node object itself is not modified, i.e. its capacity is taken to be the
+// current one.
+func (h k8sHelpers) UpdateExtendedResources(c *k8sclient.Clientset, n *api.Node, search string, resources ExtendedResources) error {
+	patch := extendedResourcesPatch(n.Status.Capacity, search, resources)
+	if len(patch) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.Core().Nodes().Patch(n.Name, types.JSONPatchType, data, "status")
+	return err
+}
+
+// jsonPatchOp is one operation of a JSON patch (RFC 6902)
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value,omitempty"`
+}
+
+// extendedResourcesPatch returns a JSON patch that removes the resources of
+// capacity that contain the search string but are not in resources, and adds
+// or updates the resources in resources.
+func extendedResourcesPatch(capacity api.ResourceList, search string, resources ExtendedResources) []jsonPatchOp {
+	// Resource names contain a '/' which must be escaped in JSON pointers
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	patch := []jsonPatchOp{}
+
+	names := make([]string, 0, len(capacity))
+	for name := range capacity {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := resources[name]; !ok && strings.Contains(name, search) {
+			patch = append(patch, jsonPatchOp{Op: "remove", Path: "/status/capacity/" + escaper.Replace(name)})
+		}
+	}
+
+	names = make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strconv.FormatInt(resources[name], 10)
+		if q, ok := capacity[api.ResourceName(name)]; ok && q.String() == value {
+			continue
+		}
+		patch = append(patch, jsonPatchOp{Op: "add", Path: "/status/capacity/" + escaper.Replace(name), Value: value})
+	}
+	return patch
+}
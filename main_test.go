@@ -1,19 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	"github.com/kubernetes-incubator/node-feature-discovery/source/external"
 	"github.com/kubernetes-incubator/node-feature-discovery/source/fake"
+	"github.com/kubernetes-incubator/node-feature-discovery/source/kernel"
 	"github.com/kubernetes-incubator/node-feature-discovery/source/panic_fake"
+	"github.com/kubernetes-incubator/node-feature-discovery/source/pci"
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/vektra/errors"
 	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sclient "k8s.io/client-go/kubernetes"
 )
@@ -26,7 +35,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 		fakeFeatures := source.Features{}
 		fakeFeatureLabels := Labels{}
 		for _, f := range fakeFeatureNames {
-			fakeFeatures[f] = true
+			fakeFeatures[f] = source.BoolValue(true)
 			fakeFeatureLabels[fmt.Sprintf("%s-testSource-%s", prefix, f)] = "true"
 		}
 		fakeFeatureSource := source.FeatureSource(mockFeatureSource)
@@ -35,7 +44,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			mockFeatureSource.On("Name").Return(fakeFeatureSourceName)
 			mockFeatureSource.On("Discover").Return(fakeFeatures, nil)
 
-			returnedLabels, err := getFeatureLabels(fakeFeatureSource)
+			returnedLabels, _, err := getFeatureLabels(fakeFeatureSource)
 			Convey("Proper label is returned", func() {
 				So(returnedLabels, ShouldResemble, fakeFeatureLabels)
 			})
@@ -48,7 +57,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			expectedError := errors.New("fake error")
 			mockFeatureSource.On("Discover").Return(nil, expectedError)
 
-			returnedLabels, err := getFeatureLabels(fakeFeatureSource)
+			returnedLabels, _, err := getFeatureLabels(fakeFeatureSource)
 			Convey("No label is returned", func() {
 				So(returnedLabels, ShouldBeNil)
 			})
@@ -61,15 +70,20 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 		testHelper := APIHelpers(mockAPIHelper)
 		var mockClient *k8sclient.Clientset
 		var mockNode *api.Node
+		fakeAnnotations := Annotations{prefix + "-testSource-note": "line1\nline2"}
+		fakeResources := ExtendedResources{prefix + "-testSource-widgets": 4}
 
 		Convey("When I successfully update the node with feature labels", func() {
 			mockAPIHelper.On("GetClient").Return(mockClient, nil)
 			mockAPIHelper.On("GetNode", mockClient).Return(mockNode, nil).Once()
 			mockAPIHelper.On("AddLabels", mockNode, fakeFeatureLabels).Return().Once()
 			mockAPIHelper.On("RemoveLabels", mockNode, prefix).Return().Once()
+			mockAPIHelper.On("RemoveAnnotations", mockNode, prefix).Return().Once()
+			mockAPIHelper.On("AddAnnotations", mockNode, fakeAnnotations).Return().Once()
 			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(nil).Once()
+			mockAPIHelper.On("UpdateExtendedResources", mockClient, mockNode, prefix, fakeResources).Return(nil).Once()
 			noPublish := false
-			err := updateNodeWithFeatureLabels(testHelper, noPublish, fakeFeatureLabels)
+			err := updateNodeWithFeatureLabels(testHelper, noPublish, fakeFeatureLabels, fakeAnnotations, fakeResources)
 
 			Convey("Error is nil", func() {
 				So(err, ShouldBeNil)
@@ -80,7 +94,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(nil, expectedError)
 			noPublish := false
-			err := updateNodeWithFeatureLabels(testHelper, noPublish, fakeFeatureLabels)
+			err := updateNodeWithFeatureLabels(testHelper, noPublish, fakeFeatureLabels, fakeAnnotations, fakeResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -90,7 +104,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 		Convey("When I fail to get a mock client while advertising feature labels", func() {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(nil, expectedError)
-			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels)
+			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels, fakeAnnotations, fakeResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -101,7 +115,7 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(mockClient, nil)
 			mockAPIHelper.On("GetNode", mockClient).Return(nil, expectedError).Once()
-			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels)
+			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels, fakeAnnotations, fakeResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -114,8 +128,10 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			mockAPIHelper.On("GetNode", mockClient).Return(mockNode, nil).Once()
 			mockAPIHelper.On("RemoveLabels", mockNode, prefix).Return().Once()
 			mockAPIHelper.On("AddLabels", mockNode, fakeFeatureLabels).Return().Once()
+			mockAPIHelper.On("RemoveAnnotations", mockNode, prefix).Return().Once()
+			mockAPIHelper.On("AddAnnotations", mockNode, fakeAnnotations).Return().Once()
 			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(expectedError).Once()
-			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels)
+			err := advertiseFeatureLabels(testHelper, fakeFeatureLabels, fakeAnnotations, fakeResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -125,12 +141,26 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 	})
 }
 
+// supportedSources returns the named sources that are supported on the
+// architecture the tests run on. Unknown names are kept, so that they fail
+// the comparison.
+func supportedSources(names ...string) []string {
+	supported := []string{}
+	for _, name := range names {
+		if r, ok := source.Lookup(name); !ok || r.Supported() {
+			supported = append(supported, name)
+		}
+	}
+	return supported
+}
+
 func TestArgsParse(t *testing.T) {
 	Convey("When parsing command line arguments", t, func() {
 		argv1 := []string{"--no-publish", "--oneshot"}
 		argv2 := []string{"--sources=fake1,fake2,fake3", "--sleep-interval=30s"}
 		argv3 := []string{"--label-whitelist=.*rdt.*"}
 		argv4 := []string{"--no-publish", "--sources=fake1,fake2,fake3"}
+		defaultSources := supportedSources("bpf", "cgroup", "cpu", "cpuid", "external", "iommu", "kernel", "local", "memory", "network", "os", "pci", "pstate", "rapl", "rdt", "security", "selinux", "storage", "syscalls")
 
 		Convey("When --no-publish and --oneshot flags are passed", func() {
 			args := argsParse(argv1)
@@ -139,7 +169,7 @@ func TestArgsParse(t *testing.T) {
 				So(args.sleepInterval, ShouldEqual, 60*time.Second)
 				So(args.noPublish, ShouldBeTrue)
 				So(args.oneshot, ShouldBeTrue)
-				So(args.sources, ShouldResemble, []string{"cpu", "cpuid", "iommu", "kernel", "local", "memory", "network", "os", "pci", "pstate", "rapl", "rdt", "selinux", "storage"})
+				So(args.sources, ShouldResemble, defaultSources)
 				So(len(args.labelWhiteList), ShouldEqual, 0)
 			})
 		})
@@ -161,7 +191,7 @@ func TestArgsParse(t *testing.T) {
 
 			Convey("args.labelWhiteList is set to appropriate value and args.sources is set to default value", func() {
 				So(args.noPublish, ShouldBeFalse)
-				So(args.sources, ShouldResemble, []string{"cpu", "cpuid", "iommu", "kernel", "local", "memory", "network", "os", "pci", "pstate", "rapl", "rdt", "selinux", "storage"})
+				So(args.sources, ShouldResemble, defaultSources)
 				So(args.labelWhiteList, ShouldResemble, ".*rdt.*")
 			})
 		})
@@ -195,6 +225,8 @@ func TestConfigParse(t *testing.T) {
   kernel:
     configOpts:
       - "DMI"
+      - name: "HZ"
+        value: true
   pci:
     deviceClassWhitelist:
       - "ff"`)
@@ -205,13 +237,44 @@ func TestConfigParse(t *testing.T) {
 
 			Convey("Should return error", func() {
 				So(err, ShouldBeNil)
-				So(config.Sources.Kernel.ConfigOpts, ShouldResemble, []string{"DMI"})
-				So(config.Sources.Pci.DeviceClassWhitelist, ShouldResemble, []string{"ff"})
+				So(sourceConfig("kernel").(*kernel.NFDConfig).ConfigOpts, ShouldResemble, []kernel.ConfigOpt{{Name: "DMI"}, {Name: "HZ", Value: true}})
+				So(sourceConfig("pci").(*pci.NFDConfig).DeviceClassWhitelist, ShouldResemble, []string{"ff"})
+			})
+		})
+
+		Convey("When config options are overridden from the command line", func() {
+			err := configParse(f.Name(), `{"sources": {"pci": {"deviceLabelFields": ["vendor"]}}}`)
+
+			Convey("Overrides should be merged with the config file and defaults", func() {
+				So(err, ShouldBeNil)
+				So(sourceConfig("kernel").(*kernel.NFDConfig).ConfigOpts, ShouldResemble, []kernel.ConfigOpt{{Name: "DMI"}, {Name: "HZ", Value: true}})
+				pciConfig := sourceConfig("pci").(*pci.NFDConfig)
+				So(pciConfig.DeviceClassWhitelist, ShouldResemble, []string{"ff"})
+				So(pciConfig.DeviceLabelFields, ShouldResemble, []string{"vendor"})
+			})
+		})
+
+		Convey("When invalid config options are given for one source", func() {
+			pciConfig := sourceConfig("pci")
+			err := configParse(f.Name(), `{"sources": {"pci": {"deviceClassWhitelist": "ff"}, "kernel": {"configOpts": ["NO_HZ"]}}}`)
+
+			Convey("Should return error and leave the config of that source untouched", func() {
+				So(err, ShouldNotBeNil)
+				So(sourceConfig("pci"), ShouldEqual, pciConfig)
+			})
+			Convey("Should apply the config of other sources", func() {
+				So(sourceConfig("kernel").(*kernel.NFDConfig).ConfigOpts, ShouldResemble, []kernel.ConfigOpt{{Name: "NO_HZ"}})
 			})
 		})
 	})
 }
 
+// sourceConfig returns the current config of a registered source
+func sourceConfig(name string) source.Config {
+	r, _ := source.Lookup(name)
+	return r.Source.(source.ConfigurableSource).GetConfig()
+}
+
 func TestConfigureParameters(t *testing.T) {
 	Convey("When configuring parameters for node feature discovery", t, func() {
 
@@ -246,6 +309,17 @@ func TestConfigureParameters(t *testing.T) {
 			})
 		})
 
+		Convey("When sourcesWhiteList contains unknown sources", func() {
+			sourcesWhiteList := []string{"fake", "non-existent-source"}
+			enabledSources, _, err := configureParameters(sourcesWhiteList, "")
+
+			Convey("Unknown sources are ignored", func() {
+				So(err, ShouldBeNil)
+				So(len(enabledSources), ShouldEqual, 1)
+				So(enabledSources[0], ShouldHaveSameTypeAs, fake.Source{})
+			})
+		})
+
 		Convey("When invalid labelWhiteListStr is passed", func() {
 			sourcesWhiteList := []string{""}
 			labelWhiteListStr := "*"
@@ -282,7 +356,7 @@ func TestCreateFeatureLabels(t *testing.T) {
 			fakeFeatureSource := source.FeatureSource(new(fake.Source))
 			sources := []source.FeatureSource{}
 			sources = append(sources, fakeFeatureSource)
-			labels := createFeatureLabels(sources, emptyLabelWL)
+			labels, _, _ := createFeatureLabels(sources, emptyLabelWL)
 
 			Convey("Proper fake labels are returned", func() {
 				So(len(labels), ShouldEqual, 4)
@@ -296,7 +370,7 @@ func TestCreateFeatureLabels(t *testing.T) {
 			fakeFeatureSource := source.FeatureSource(new(fake.Source))
 			sources := []source.FeatureSource{}
 			sources = append(sources, fakeFeatureSource)
-			labels := createFeatureLabels(sources, emptyLabelWL)
+			labels, _, _ := createFeatureLabels(sources, emptyLabelWL)
 
 			Convey("fake labels are not returned", func() {
 				So(len(labels), ShouldEqual, 1)
@@ -308,6 +382,83 @@ func TestCreateFeatureLabels(t *testing.T) {
 	})
 }
 
+func TestExplain(t *testing.T) {
+	Convey("When explain mode is enabled", t, func() {
+		var buf bytes.Buffer
+		source.SetExplainOutput(&buf)
+		defer source.SetExplainOutput(nil)
+
+		labelWL, _ := regexp.Compile(".*fakefeature[12]")
+		sources := []source.FeatureSource{fake.Source{}}
+		createFeatureLabels(sources, labelWL)
+
+		Convey("Published and filtered labels are explained", func() {
+			out := buf.String()
+			So(out, ShouldContainSubstring, "source [fake]:")
+			So(out, ShouldContainSubstring, prefix+"-fake-fakefeature1 = true: published")
+			So(out, ShouldContainSubstring, prefix+"-fake-fakefeature3 = true: not published, does not match the whitelist")
+		})
+	})
+}
+
+func TestExternalSources(t *testing.T) {
+	Convey("When discovering features from external sources", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		group := &external.Group{}
+		group.SetConfig(&external.NFDConfig{SocketDir: dir, Timeout: source.Duration{Duration: 5 * time.Second}})
+
+		// Fake external source daemon replying to one request
+		l, err := net.Listen("unix", filepath.Join(dir, "ext-test.sock"))
+		So(err, ShouldBeNil)
+		defer l.Close()
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			req := external.Request{}
+			json.NewDecoder(conn).Decode(&req)
+			json.NewEncoder(conn).Encode(external.Response{
+				APIVersion: external.APIVersion,
+				Features:   map[string]interface{}{"feature1": true, "feature2": 42},
+			})
+		}()
+
+		emptyLabelWL, _ := regexp.Compile("")
+		sources := []source.FeatureSource{group, fake.Source{}}
+		labels, _, _ := createFeatureLabels(sources, emptyLabelWL)
+
+		Convey("Labels of the external source are namespaced by its name", func() {
+			So(labels, ShouldContainKey, prefix+"-fake-fakefeature1")
+			So(labels[prefix+"-ext-test-feature1"], ShouldEqual, "true")
+			So(labels[prefix+"-ext-test-feature2"], ShouldEqual, "42")
+			So(labels, ShouldNotContainKey, prefix+"-external-feature1")
+		})
+	})
+}
+
+func TestExtendedResourcesPatch(t *testing.T) {
+	Convey("When patching extended resources", t, func() {
+		capacity := api.ResourceList{
+			"cpu":                           resource.MustParse("4"),
+			api.ResourceName(prefix + "-a"): resource.MustParse("1"),
+			api.ResourceName(prefix + "-b"): resource.MustParse("2"),
+		}
+		patch := extendedResourcesPatch(capacity, prefix, ExtendedResources{prefix + "-b": 2, prefix + "-c": 3})
+
+		Convey("Stale resources are removed and new ones added", func() {
+			escaped := strings.Replace(prefix, "/", "~1", -1)
+			So(patch, ShouldResemble, []jsonPatchOp{
+				{Op: "remove", Path: "/status/capacity/" + escaped + "-a"},
+				{Op: "add", Path: "/status/capacity/" + escaped + "-c", Value: "3"},
+			})
+		})
+	})
+}
+
 func TestAddLabels(t *testing.T) {
 	Convey("When adding labels", t, func() {
 		helper := k8sHelpers{}
@@ -375,7 +526,7 @@ func TestGetFeatureLabels(t *testing.T) {
 	Convey("When I get feature labels and panic occurs during discovery of a feature source", t, func() {
 		fakePanicFeatureSource := source.FeatureSource(new(panic_fake.Source))
 
-		returnedLabels, err := getFeatureLabels(fakePanicFeatureSource)
+		returnedLabels, _, err := getFeatureLabels(fakePanicFeatureSource)
 		Convey("No label is returned", func() {
 			So(len(returnedLabels), ShouldEqual, 0)
 		})
@@ -70,6 +70,18 @@ func (_m *MockAPIHelpers) AddLabels(_a0 *api.Node, _a1 Labels) {
 	_m.Called(_a0, _a1)
 }
 
+// RemoveAnnotations provides a mock function with *api.Node and string as the input arguments and
+// no return value
+func (_m *MockAPIHelpers) RemoveAnnotations(_a0 *api.Node, _a1 string) {
+	_m.Called(_a0, _a1)
+}
+
+// AddAnnotations provides a mock function with *api.Node and main.Annotations as the input arguments and
+// no return value
+func (_m *MockAPIHelpers) AddAnnotations(_a0 *api.Node, _a1 Annotations) {
+	_m.Called(_a0, _a1)
+}
+
 // UpdateNode provides a mock function with *k8sclient.Clientset and *api.Node as the input arguments and
 // error as the return value
 func (_m *MockAPIHelpers) UpdateNode(_a0 *k8sclient.Clientset, _a1 *api.Node) error {
@@ -84,3 +96,18 @@ func (_m *MockAPIHelpers) UpdateNode(_a0 *k8sclient.Clientset, _a1 *api.Node) er
 
 	return r0
 }
+
+// UpdateExtendedResources provides a mock function with *k8sclient.Clientset, *api.Node, string and
+// main.ExtendedResources as the input arguments and error as the return value
+func (_m *MockAPIHelpers) UpdateExtendedResources(_a0 *k8sclient.Clientset, _a1 *api.Node, _a2 string, _a3 ExtendedResources) error {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*k8sclient.Clientset, *api.Node, string, ExtendedResources) error); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
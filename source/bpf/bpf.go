@@ -0,0 +1,137 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bpf
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Configuration file options
+type NFDConfig struct {
+	// Probe the supported program and map types with the bpf() system call
+	ProbeTypes bool `json:"probeTypes"`
+}
+
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *NFDConfig {
+	return &NFDConfig{
+		ProbeTypes: true,
+	}
+}
+
+// Implement FeatureSource and ConfigurableSource interfaces
+type Source struct {
+	config *NFDConfig
+	// Error of the last failed probing, which is logged only when it changes
+	probeErr string
+}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         &Source{config: newDefaultConfig()},
+		Description:    "eBPF capabilities, e.g. BTF, JIT and supported program and map types",
+		DefaultEnabled: true,
+		// Number of the bpf() system call is only known on these
+		Archs: []string{"amd64", "arm64"},
+	})
+}
+
+func (s *Source) Name() string { return "bpf" }
+
+// NewConfig method of the ConfigurableSource interface
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
+
+// GetConfig method of the ConfigurableSource interface
+func (s *Source) GetConfig() source.Config { return s.config }
+
+// SetConfig method of the ConfigurableSource interface
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *NFDConfig:
+		s.config = v
+	default:
+		panic(fmt.Sprintf("invalid config type: %T", conf))
+	}
+}
+
+func (s *Source) Discover() (source.Features, error) {
+	features := settingsFeatures(source.HostSysDir, source.HostProcDir)
+
+	if s.config.ProbeTypes {
+		err := probeFeatures(features)
+		if err != nil {
+			// E.g. missing privileges do not change between discovery
+			// rounds
+			source.Explainf("prog-type, map-type: %v", err)
+			if err.Error() != s.probeErr {
+				logger.Printf("Failed to probe BPF program and map types: %s", err)
+			}
+			s.probeErr = err.Error()
+		} else {
+			s.probeErr = ""
+		}
+	}
+	return features, nil
+}
+
+// settingsFeatures returns the features readable from the sysfs and procfs
+// at sysDir and procDir, respectively
+func settingsFeatures(sysDir string, procDir string) source.Features {
+	features := source.Features{}
+
+	// BTF of the kernel, needed by CO-RE BPF programs
+	btf := filepath.Join(sysDir, "kernel/btf/vmlinux")
+	if _, err := os.Stat(btf); err == nil {
+		source.Explainf("btf: found %s", btf)
+		features["btf"] = source.BoolValue(true)
+	} else {
+		source.Explainf("btf: %v", err)
+	}
+
+	// 0: unprivileged BPF is allowed, 1: disabled until reboot, 2: disabled
+	if n, err := readInt(filepath.Join(procDir, "sys/kernel/unprivileged_bpf_disabled")); err == nil {
+		features["unprivileged-disabled"] = source.IntValue(n)
+	}
+
+	// 0: disabled, 1: enabled, 2: enabled with debug output
+	if n, err := readInt(filepath.Join(procDir, "sys/net/core/bpf_jit_enable")); err == nil && n != 0 {
+		features["jit.enabled"] = source.BoolValue(true)
+	}
+	// 0: disabled, 1: unprivileged programs only, 2: all programs
+	if n, err := readInt(filepath.Join(procDir, "sys/net/core/bpf_jit_harden")); err == nil {
+		features["jit.harden"] = source.IntValue(n)
+	}
+
+	return features
+}
+
+func readInt(path string) (int64, error) {
+	raw, err := source.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSettings(t *testing.T) {
+	Convey("When reading BPF settings", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-bpf-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		for path, value := range map[string]string{
+			"sys/kernel/btf/vmlinux":                    "",
+			"proc/sys/kernel/unprivileged_bpf_disabled": "2\n",
+			"proc/sys/net/core/bpf_jit_enable":          "1\n",
+			"proc/sys/net/core/bpf_jit_harden":          "0\n",
+		} {
+			So(os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(dir, path), []byte(value), 0644), ShouldBeNil)
+		}
+
+		So(settingsFeatures(filepath.Join(dir, "sys"), filepath.Join(dir, "proc")), ShouldResemble, source.Features{
+			"btf":                   source.BoolValue(true),
+			"unprivileged-disabled": source.IntValue(2),
+			"jit.enabled":           source.BoolValue(true),
+			"jit.harden":            source.IntValue(0),
+		})
+	})
+
+	Convey("The bpf_attr layouts match the kernel", t, func() {
+		So(unsafe.Offsetof(progLoadAttr{}.expectedAttachType), ShouldEqual, 68)
+		So(unsafe.Offsetof(progLoadAttr{}.attachBTFID), ShouldEqual, 108)
+		So(unsafe.Offsetof(mapCreateAttr{}.btfVmlinuxValueTypeID), ShouldEqual, 60)
+		So(unsafe.Sizeof(mapCreateAttr{}), ShouldEqual, 72)
+		So(unsafe.Sizeof(bpfInsn{}), ShouldEqual, 8)
+	})
+}
@@ -0,0 +1,364 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bpf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Commands and flags of the bpf() system call
+const (
+	bpfMapCreate = 0
+	bpfProgLoad  = 5
+
+	bpfFNoPrealloc = 1 << 0
+	bpfFSleepable  = 1 << 4
+
+	// Attach types required by some program types
+	bpfCgroupInet4Connect = 10
+	bpfLircMode2          = 16
+	bpfCgroupGetsockopt   = 21
+	bpfTraceFentry        = 24
+	bpfLSMMac             = 27
+	bpfSkLookup           = 36
+	bpfNetfilter          = 45
+
+	// Kernel internal error code, returned e.g. for invalid struct_ops
+	enotsupp = syscall.Errno(524)
+
+	// Not defined in the syscall package
+	rlimitMemlock = 8
+)
+
+// progLoadAttr is the BPF_PROG_LOAD variant of union bpf_attr, up to
+// attach_btf_id
+type progLoadAttr struct {
+	progType           uint32
+	insnCnt            uint32
+	insns              uint64
+	license            uint64
+	logLevel           uint32
+	logSize            uint32
+	logBuf             uint64
+	kernVersion        uint32
+	progFlags          uint32
+	progName           [16]byte
+	progIfindex        uint32
+	expectedAttachType uint32
+	progBTFFd          uint32
+	funcInfoRecSize    uint32
+	funcInfo           uint64
+	funcInfoCnt        uint32
+	lineInfoRecSize    uint32
+	lineInfo           uint64
+	lineInfoCnt        uint32
+	attachBTFID        uint32
+}
+
+// mapCreateAttr is the BPF_MAP_CREATE variant of union bpf_attr, up to
+// map_extra
+type mapCreateAttr struct {
+	mapType               uint32
+	keySize               uint32
+	valueSize             uint32
+	maxEntries            uint32
+	mapFlags              uint32
+	innerMapFd            uint32
+	numaNode              uint32
+	mapName               [16]byte
+	mapIfindex            uint32
+	btfFd                 uint32
+	btfKeyTypeID          uint32
+	btfValueTypeID        uint32
+	btfVmlinuxValueTypeID uint32
+	mapExtra              uint64
+}
+
+// bpfInsn is one BPF instruction
+type bpfInsn struct {
+	code uint8
+	regs uint8
+	off  int16
+	imm  int32
+}
+
+// The program loaded for probing program types: "r0 = 0; exit"
+var probeInsns = []bpfInsn{{code: 0xb7}, {code: 0x95}}
+
+var probeLicense = []byte("GPL\x00")
+
+// Verifier log of probes. The kernel only knows the addresses of the buffers
+// passed to it, which must thus not be on the stack of the goroutine, as it
+// could be moved.
+var probeLog = make([]byte, 4096)
+
+// progType describes how to probe a program type. Program types that need
+// BTF are probed by referring to an invalid BTF type, in which case the
+// verifier rejects the program with the expected error and log message if
+// the program type is supported.
+type progType struct {
+	name        string
+	attachType  uint32
+	flags       uint32
+	btfID       uint32
+	expectedErr syscall.Errno
+	expectedMsg string
+}
+
+// Program types, indexed by enum bpf_prog_type
+var progTypes = []progType{
+	1:  {name: "socket_filter"},
+	2:  {name: "kprobe"},
+	3:  {name: "sched_cls"},
+	4:  {name: "sched_act"},
+	5:  {name: "tracepoint"},
+	6:  {name: "xdp"},
+	7:  {name: "perf_event"},
+	8:  {name: "cgroup_skb"},
+	9:  {name: "cgroup_sock"},
+	10: {name: "lwt_in"},
+	11: {name: "lwt_out"},
+	12: {name: "lwt_xmit"},
+	13: {name: "sock_ops"},
+	14: {name: "sk_skb"},
+	15: {name: "cgroup_device"},
+	16: {name: "sk_msg"},
+	17: {name: "raw_tracepoint"},
+	18: {name: "cgroup_sock_addr", attachType: bpfCgroupInet4Connect},
+	19: {name: "lwt_seg6local"},
+	20: {name: "lirc_mode2", attachType: bpfLircMode2},
+	21: {name: "sk_reuseport"},
+	22: {name: "flow_dissector"},
+	23: {name: "cgroup_sysctl"},
+	24: {name: "raw_tracepoint_writable"},
+	25: {name: "cgroup_sockopt", attachType: bpfCgroupGetsockopt},
+	26: {name: "tracing", attachType: bpfTraceFentry, btfID: 1, expectedErr: syscall.EINVAL, expectedMsg: "attach_btf_id 1 is not a function"},
+	27: {name: "struct_ops", expectedErr: enotsupp},
+	28: {name: "ext", btfID: 1, expectedErr: syscall.EINVAL, expectedMsg: "Cannot replace kernel functions"},
+	29: {name: "lsm", attachType: bpfLSMMac, btfID: 1, expectedErr: syscall.EINVAL, expectedMsg: "attach_btf_id 1 is not a function"},
+	30: {name: "sk_lookup", attachType: bpfSkLookup},
+	31: {name: "syscall", flags: bpfFSleepable},
+	32: {name: "netfilter", attachType: bpfNetfilter},
+}
+
+// mapType describes how to probe a map type
+type mapType struct {
+	name       string
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	flags      uint32
+	// Use the page size as max_entries
+	pageSized bool
+	// Needs an inner map
+	ofMaps             bool
+	vmlinuxValueTypeID uint32
+	expectedErr        syscall.Errno
+}
+
+// Map types, indexed by enum bpf_map_type. Storage maps that need BTF of
+// their own are not probed.
+var mapTypes = []mapType{
+	1:  {name: "hash", keySize: 4, valueSize: 4, maxEntries: 1},
+	2:  {name: "array", keySize: 4, valueSize: 4, maxEntries: 1},
+	3:  {name: "prog_array", keySize: 4, valueSize: 4, maxEntries: 1},
+	4:  {name: "perf_event_array", keySize: 4, valueSize: 4, maxEntries: 1},
+	5:  {name: "percpu_hash", keySize: 4, valueSize: 4, maxEntries: 1},
+	6:  {name: "percpu_array", keySize: 4, valueSize: 4, maxEntries: 1},
+	7:  {name: "stack_trace", keySize: 4, valueSize: 8, maxEntries: 1},
+	8:  {name: "cgroup_array", keySize: 4, valueSize: 4, maxEntries: 1},
+	9:  {name: "lru_hash", keySize: 4, valueSize: 4, maxEntries: 1},
+	10: {name: "lru_percpu_hash", keySize: 4, valueSize: 4, maxEntries: 1},
+	11: {name: "lpm_trie", keySize: 8, valueSize: 8, maxEntries: 1, flags: bpfFNoPrealloc},
+	12: {name: "array_of_maps", keySize: 4, valueSize: 4, maxEntries: 1, ofMaps: true},
+	13: {name: "hash_of_maps", keySize: 4, valueSize: 4, maxEntries: 1, ofMaps: true},
+	14: {name: "devmap", keySize: 4, valueSize: 4, maxEntries: 1},
+	15: {name: "sockmap", keySize: 4, valueSize: 4, maxEntries: 1},
+	16: {name: "cpumap", keySize: 4, valueSize: 4, maxEntries: 1},
+	17: {name: "xskmap", keySize: 4, valueSize: 4, maxEntries: 1},
+	18: {name: "sockhash", keySize: 4, valueSize: 4, maxEntries: 1},
+	19: {name: "cgroup_storage", keySize: 12, valueSize: 4},
+	20: {name: "reuseport_sockarray", keySize: 4, valueSize: 4, maxEntries: 1},
+	21: {name: "percpu_cgroup_storage", keySize: 12, valueSize: 4},
+	22: {name: "queue", valueSize: 4, maxEntries: 1},
+	23: {name: "stack", valueSize: 4, maxEntries: 1},
+	25: {name: "devmap_hash", keySize: 4, valueSize: 4, maxEntries: 1},
+	26: {name: "struct_ops", keySize: 4, valueSize: 4, maxEntries: 1, vmlinuxValueTypeID: 1, expectedErr: enotsupp},
+	27: {name: "ringbuf", pageSized: true},
+	30: {name: "bloom_filter", valueSize: 4, maxEntries: 1},
+	31: {name: "user_ringbuf", pageSized: true},
+}
+
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := syscall.Syscall(uintptr(sysBPF), cmd, uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// kernelVersionCode returns the version of the running kernel in the form
+// of LINUX_VERSION_CODE, required for loading kprobe programs in old kernels
+func kernelVersionCode() uint32 {
+	uts := syscall.Utsname{}
+	if err := syscall.Uname(&uts); err != nil {
+		return 0
+	}
+	release := []byte{}
+	for _, c := range uts.Release {
+		if c == 0 {
+			break
+		}
+		release = append(release, byte(c))
+	}
+	v, err := source.ParseVersion(string(bytes.SplitN(release, []byte("-"), 2)[0]))
+	if err != nil {
+		return 0
+	}
+	if v.Patch > 255 {
+		v.Patch = 255
+	}
+	return uint32(v.Major<<16 + v.Minor<<8 + v.Patch)
+}
+
+// probeProgType tells if the kernel supports a program type, returning the
+// error of loading the program, too
+func probeProgType(typ uint32, p progType, kernVersion uint32) (bool, error) {
+	for i := range probeLog {
+		probeLog[i] = 0
+	}
+	attr := progLoadAttr{
+		progType:           typ,
+		insnCnt:            uint32(len(probeInsns)),
+		insns:              uint64(uintptr(unsafe.Pointer(&probeInsns[0]))),
+		license:            uint64(uintptr(unsafe.Pointer(&probeLicense[0]))),
+		kernVersion:        kernVersion,
+		progFlags:          p.flags,
+		expectedAttachType: p.attachType,
+		attachBTFID:        p.btfID,
+	}
+	if p.expectedMsg != "" {
+		attr.logLevel = 1
+		attr.logSize = uint32(len(probeLog))
+		attr.logBuf = uint64(uintptr(unsafe.Pointer(&probeLog[0])))
+	}
+	fd, err := bpf(bpfProgLoad, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == nil {
+		syscall.Close(fd)
+		return p.expectedErr == 0, nil
+	}
+	if p.expectedErr == 0 || err != p.expectedErr {
+		return false, err
+	}
+	return p.expectedMsg == "" || bytes.Contains(probeLog, []byte(p.expectedMsg)), err
+}
+
+// createMap creates a map of a type, returning its fd
+func createMap(typ uint32, m mapType, innerMapFd int) (int, error) {
+	attr := mapCreateAttr{
+		mapType:               typ,
+		keySize:               m.keySize,
+		valueSize:             m.valueSize,
+		maxEntries:            m.maxEntries,
+		mapFlags:              m.flags,
+		innerMapFd:            uint32(innerMapFd),
+		btfVmlinuxValueTypeID: m.vmlinuxValueTypeID,
+	}
+	if m.pageSized {
+		attr.maxEntries = uint32(os.Getpagesize())
+	}
+	return bpf(bpfMapCreate, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// probeMapType tells if the kernel supports a map type
+func probeMapType(typ uint32, m mapType) bool {
+	innerMapFd := 0
+	if m.ofMaps {
+		fd, err := createMap(2, mapTypes[2], 0)
+		if err != nil {
+			return false
+		}
+		defer syscall.Close(fd)
+		innerMapFd = fd
+	}
+	fd, err := createMap(typ, m, innerMapFd)
+	if err == nil {
+		syscall.Close(fd)
+		return m.expectedErr == 0
+	}
+	return m.expectedErr != 0 && err == m.expectedErr
+}
+
+// probeFeatures adds the supported program and map types to features, by
+// loading a trivial program of each program type and creating a map of each
+// map type. Probing requires CAP_SYS_ADMIN or CAP_BPF.
+func probeFeatures(features source.Features) error {
+	// Memory of BPF objects is accounted against RLIMIT_MEMLOCK in kernels
+	// before 5.11. Objects are freed asynchronously, so the default limit
+	// may be exceeded by probing.
+	var rlim syscall.Rlimit
+	if err := syscall.Getrlimit(rlimitMemlock, &rlim); err == nil {
+		unlimited := syscall.Rlimit{Cur: ^uint64(0), Max: ^uint64(0)}
+		if err := syscall.Setrlimit(rlimitMemlock, &unlimited); err == nil {
+			defer syscall.Setrlimit(rlimitMemlock, &rlim)
+		}
+	}
+
+	// Socket filters are the most basic program type, supported by all
+	// kernels with bpf(). Failing to load one means that probing is not
+	// possible, e.g. because of missing privileges or seccomp.
+	kernVersion := kernelVersionCode()
+	if ok, err := probeProgType(1, progTypes[1], kernVersion); !ok {
+		return fmt.Errorf("unable to load BPF programs: %v", err)
+	}
+
+	progs := source.ListValue{}
+	for i, p := range progTypes {
+		if p.name == "" {
+			continue
+		}
+		if ok, _ := probeProgType(uint32(i), p, kernVersion); ok {
+			source.Explainf("prog-type.%s: program of type %d loaded", p.name, i)
+			progs = append(progs, source.StringValue(p.name))
+		} else {
+			source.Explainf("prog-type.%s: program of type %d not loaded", p.name, i)
+		}
+	}
+	features["prog-type"] = progs
+
+	maps := source.ListValue{}
+	for i, m := range mapTypes {
+		if m.name == "" {
+			continue
+		}
+		if probeMapType(uint32(i), m) {
+			source.Explainf("map-type.%s: map of type %d created", m.name, i)
+			maps = append(maps, source.StringValue(m.name))
+		} else {
+			source.Explainf("map-type.%s: map of type %d not created", m.name, i)
+		}
+	}
+	features["map-type"] = maps
+
+	return nil
+}
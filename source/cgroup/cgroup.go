@@ -0,0 +1,153 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
+type Source struct{}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Cgroup version, controllers and pressure stall information",
+		DefaultEnabled: true,
+	})
+}
+
+func (s Source) Name() string { return "cgroup" }
+
+func (s Source) Discover() (source.Features, error) {
+	return discover(source.HostProcDir, source.HostSysDir), nil
+}
+
+// Controllers of cgroup v1
+var v1Controllers = map[string]bool{
+	"blkio":      true,
+	"cpu":        true,
+	"cpuacct":    true,
+	"cpuset":     true,
+	"devices":    true,
+	"freezer":    true,
+	"hugetlb":    true,
+	"memory":     true,
+	"misc":       true,
+	"net_cls":    true,
+	"net_prio":   true,
+	"perf_event": true,
+	"pids":       true,
+	"rdma":       true,
+}
+
+// Resources of pressure stall information
+var psiResources = []string{"cpu", "io", "irq", "memory"}
+
+// discover detects the cgroup features of the host, with procfs and sysfs
+// at procDir and sysDir, respectively. The cgroup mode and controllers are
+// derived from the hierarchies under fs/cgroup of the host sysfs, as the
+// mounts of the NFD container need not match those of the host.
+func discover(procDir string, sysDir string) source.Features {
+	features := source.Features{}
+
+	cgroupDir := filepath.Join(sysDir, "fs/cgroup")
+	controllers := map[string]bool{}
+	v1, v2 := false, false
+	// In the v2 mode the root cgroup is mounted at fs/cgroup. Otherwise
+	// fs/cgroup is a tmpfs with a directory per hierarchy, e.g. "memory" or
+	// "cpu,cpuacct" for v1 and, in the hybrid mode, "unified" for v2.
+	if raw, err := source.ReadFile(filepath.Join(cgroupDir, "cgroup.controllers")); err == nil {
+		v2 = true
+		addV2Controllers(controllers, raw)
+	} else {
+		entries, err := ioutil.ReadDir(cgroupDir)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Printf("ERROR: Failed to read cgroup hierarchies: %s", err)
+		}
+		for _, e := range entries {
+			dir := filepath.Join(cgroupDir, e.Name())
+			if raw, err := source.ReadFile(filepath.Join(dir, "cgroup.controllers")); err == nil {
+				// In the hybrid mode all controllers are usually bound to
+				// v1 hierarchies
+				v2 = true
+				addV2Controllers(controllers, raw)
+			} else if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err == nil {
+				v1 = true
+				// Other hierarchy names, e.g. "systemd", are not controllers
+				for _, c := range strings.Split(e.Name(), ",") {
+					if v1Controllers[c] {
+						controllers[c] = true
+					}
+				}
+			}
+		}
+	}
+
+	switch {
+	case v1 && v2:
+		features["mode"] = source.StringValue("hybrid")
+	case v1:
+		features["mode"] = source.StringValue("v1")
+	case v2:
+		features["mode"] = source.StringValue("v2")
+	default:
+		source.Explainf("mode: no cgroup hierarchies in %s", cgroupDir)
+	}
+
+	if len(controllers) > 0 {
+		names := []string{}
+		for c := range controllers {
+			names = append(names, c)
+		}
+		sort.Strings(names)
+		list := source.ListValue{}
+		for _, c := range names {
+			list = append(list, source.StringValue(c))
+		}
+		features["controllers"] = list
+	}
+
+	// The pressure files do not exist if PSI is disabled, e.g. with psi=0
+	// on the kernel command line
+	psi := source.ListValue{}
+	for _, r := range psiResources {
+		if _, err := source.ReadFile(filepath.Join(procDir, "pressure", r)); err == nil {
+			psi = append(psi, source.StringValue(r))
+		}
+	}
+	if len(psi) > 0 {
+		features["psi"] = psi
+	}
+
+	return features
+}
+
+// addV2Controllers adds the controllers listed in a cgroup.controllers file
+func addV2Controllers(controllers map[string]bool, raw []byte) {
+	for _, c := range strings.Fields(string(raw)) {
+		controllers[c] = true
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeFiles(dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	Convey("When discovering cgroup features", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-cgroup-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		procDir := filepath.Join(dir, "proc")
+		sysDir := filepath.Join(dir, "sys")
+
+		Convey("The hybrid mode and v1 controllers are detected", func() {
+			writeFiles(sysDir, map[string]string{
+				"fs/cgroup/cpu,cpuacct/cgroup.procs":   "1\n",
+				"fs/cgroup/memory/cgroup.procs":        "1\n",
+				"fs/cgroup/systemd/cgroup.procs":       "1\n",
+				"fs/cgroup/unified/cgroup.procs":       "1\n",
+				"fs/cgroup/unified/cgroup.controllers": "\n",
+			})
+			So(os.Symlink("cpu,cpuacct", filepath.Join(sysDir, "fs/cgroup/cpu")), ShouldBeNil)
+
+			So(discover(procDir, sysDir), ShouldResemble, source.Features{
+				"mode": source.StringValue("hybrid"),
+				"controllers": source.ListValue{
+					source.StringValue("cpu"),
+					source.StringValue("cpuacct"),
+					source.StringValue("memory"),
+				},
+			})
+		})
+
+		Convey("The v2 mode, v2 controllers and PSI are detected", func() {
+			writeFiles(procDir, map[string]string{
+				"pressure/cpu":    "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+				"pressure/io":     "",
+				"pressure/memory": "",
+			})
+			writeFiles(sysDir, map[string]string{
+				"fs/cgroup/cgroup.procs":       "1\n",
+				"fs/cgroup/cgroup.controllers": "cpuset cpu io memory hugetlb pids\n",
+				// Child cgroups are not hierarchies
+				"fs/cgroup/system.slice/cgroup.procs":       "",
+				"fs/cgroup/system.slice/cgroup.controllers": "memory pids\n",
+			})
+
+			So(discover(procDir, sysDir), ShouldResemble, source.Features{
+				"mode": source.StringValue("v2"),
+				"controllers": source.ListValue{
+					source.StringValue("cpu"),
+					source.StringValue("cpuset"),
+					source.StringValue("hugetlb"),
+					source.StringValue("io"),
+					source.StringValue("memory"),
+					source.StringValue("pids"),
+				},
+				"psi": source.ListValue{
+					source.StringValue("cpu"),
+					source.StringValue("io"),
+					source.StringValue("memory"),
+				},
+			})
+		})
+
+		Convey("The mode is not set without cgroup hierarchies", func() {
+			writeFiles(sysDir, map[string]string{"fs/cgroup/.keep": ""})
+
+			So(discover(procDir, sysDir), ShouldResemble, source.Features{})
+		})
+	})
+}
@@ -27,6 +27,14 @@ import (
 // Implement FeatureSource interface
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "CPU features that are enabled, e.g. hardware multithreading",
+		DefaultEnabled: true,
+	})
+}
+
 func (s Source) Name() string { return "cpu" }
 
 func (s Source) Discover() (source.Features, error) {
@@ -37,7 +45,10 @@ func (s Source) Discover() (source.Features, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to detect hyper-threading: %v", err)
 	} else if found {
-		features["hardware_multithreading"] = true
+		source.Explainf("hardware_multithreading: CPUs with thread siblings found")
+		features["hardware_multithreading"] = source.BoolValue(true)
+	} else {
+		source.Explainf("hardware_multithreading: no CPU has thread siblings")
 	}
 	return features, nil
 }
@@ -52,7 +63,7 @@ func haveThreadSiblings() (bool, error) {
 
 	for _, file := range files {
 		// Try to read siblings from topology
-		siblings, err := ioutil.ReadFile(path.Join(baseDir, file.Name(), "topology/thread_siblings_list"))
+		siblings, err := source.ReadFile(path.Join(baseDir, file.Name(), "topology/thread_siblings_list"))
 		if err != nil {
 			return false, err
 		}
@@ -17,6 +17,8 @@ limitations under the License.
 package cpuid
 
 import (
+	"strings"
+
 	"github.com/klauspost/cpuid"
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
 )
@@ -24,6 +26,14 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "CPU capabilities reported by the cpuid instruction",
+		DefaultEnabled: true,
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "cpuid" }
 
@@ -31,8 +41,9 @@ func (s Source) Name() string { return "cpuid" }
 func (s Source) Discover() (source.Features, error) {
 	// Get the cpu features as strings
 	features := source.Features{}
+	source.Explainf("cpuid reports features: %s", strings.Join(cpuid.CPU.Features.Strings(), " "))
 	for _, f := range cpuid.CPU.Features.Strings() {
-		features[f] = true
+		features[f] = source.BoolValue(true)
 	}
 	return features, nil
 }
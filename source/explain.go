@@ -0,0 +1,96 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// Maximum number of bytes of file contents recorded as evidence
+const maxExplainData = 512
+
+var explain struct {
+	sync.Mutex
+	w io.Writer
+}
+
+// SetExplainOutput enables explain mode, in which sources record the evidence
+// behind their discovery results, and the decisions taken, into w. A nil
+// writer disables explain mode.
+func SetExplainOutput(w io.Writer) {
+	explain.Lock()
+	defer explain.Unlock()
+	explain.w = w
+}
+
+// Explaining tells if explain mode is enabled. Sources may use it to skip
+// gathering evidence that is expensive to produce.
+func Explaining() bool {
+	explain.Lock()
+	defer explain.Unlock()
+	return explain.w != nil
+}
+
+// ExplainTitlef starts a new section, e.g. for one source, in the explain
+// output.
+func ExplainTitlef(format string, args ...interface{}) {
+	explain.Lock()
+	defer explain.Unlock()
+	if explain.w == nil {
+		return
+	}
+	fmt.Fprintf(explain.w, format+"\n", args...)
+}
+
+// Explainf records one piece of evidence or decision, if explain mode is
+// enabled.
+func Explainf(format string, args ...interface{}) {
+	explain.Lock()
+	defer explain.Unlock()
+	if explain.w == nil {
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	for _, line := range strings.Split(msg, "\n") {
+		fmt.Fprintf(explain.w, "    %s\n", line)
+	}
+}
+
+// ExplainData returns data in a form suitable for recording as evidence,
+// i.e. quoted and truncated if too long.
+func ExplainData(data []byte) string {
+	if len(data) > maxExplainData {
+		return fmt.Sprintf("%q... (%d bytes)", data[:maxExplainData], len(data))
+	}
+	return fmt.Sprintf("%q", data)
+}
+
+// ReadFile reads a file like ioutil.ReadFile, recording the file contents as
+// evidence in explain mode.
+func ReadFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		Explainf("read %s: %v", filename, err)
+	} else {
+		Explainf("read %s: %s", filename, ExplainData(data))
+	}
+	return data, err
+}
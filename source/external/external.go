@@ -0,0 +1,213 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// APIVersion is the version of the external source protocol implemented by
+// this package.
+const APIVersion = "v1alpha1"
+
+// Suffix of socket files that are recognized as external sources
+const socketSuffix = ".sock"
+
+// Configuration file options
+type NFDConfig struct {
+	SocketDir string          `json:"socketDir,omitempty"`
+	Timeout   source.Duration `json:"timeout,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *NFDConfig {
+	return &NFDConfig{
+		SocketDir: "/var/run/node-feature-discovery/external/",
+		Timeout:   source.Duration{Duration: 5 * time.Second},
+	}
+}
+
+// Validate checks the sanity of the config
+func (c *NFDConfig) Validate() error {
+	if c.SocketDir == "" {
+		return fmt.Errorf("socketDir must not be empty")
+	}
+	// A stuck source daemon would block discovery without a timeout
+	if c.Timeout.Duration <= 0 {
+		return fmt.Errorf("timeout must be positive, got %v", c.Timeout)
+	}
+	return nil
+}
+
+// Valid names of external sources, i.e. socket file names without suffix
+var validNameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Request is the message sent by NFD to an external source.
+type Request struct {
+	APIVersion string `json:"apiVersion"`
+	// Method is the operation requested, currently always "discover"
+	Method string `json:"method"`
+}
+
+// Response is the message an external source sends back to NFD.
+type Response struct {
+	APIVersion string                 `json:"apiVersion"`
+	Features   map[string]interface{} `json:"features,omitempty"`
+	// Error, if non-empty, tells that discovery failed
+	Error string `json:"error,omitempty"`
+}
+
+// Group implements FeatureSource, ConfigurableSource and SourceGroup.
+// Members of the group are the external source daemons listening on sockets
+// in the configured socket directory.
+type Group struct {
+	config *NFDConfig
+}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         &Group{config: newDefaultConfig()},
+		Description:    "Features provided by external source daemons over Unix sockets",
+		DefaultEnabled: true,
+	})
+}
+
+func (g *Group) Name() string { return "external" }
+
+// NewConfig method of the ConfigurableSource interface
+func (g *Group) NewConfig() source.Config { return newDefaultConfig() }
+
+// GetConfig method of the ConfigurableSource interface
+func (g *Group) GetConfig() source.Config { return g.config }
+
+// SetConfig method of the ConfigurableSource interface
+func (g *Group) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *NFDConfig:
+		g.config = v
+	default:
+		panic(fmt.Sprintf("invalid config type: %T", conf))
+	}
+}
+
+// Discover is not supported for the group itself, features are discovered
+// from each member separately.
+func (g *Group) Discover() (source.Features, error) {
+	return nil, fmt.Errorf("features of external sources must be discovered per source")
+}
+
+// Sources returns one feature source for each socket in the socket
+// directory.
+func (g *Group) Sources() ([]source.FeatureSource, error) {
+	sources := []source.FeatureSource{}
+	socketDir := g.config.SocketDir
+
+	files, err := ioutil.ReadDir(socketDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			glog.Infof("External source directory %v does not exist", socketDir)
+			return sources, nil
+		}
+		return nil, fmt.Errorf("Unable to access %v: %v", socketDir, err)
+	}
+
+	for _, file := range files {
+		if file.Mode()&os.ModeSocket == 0 || !strings.HasSuffix(file.Name(), socketSuffix) {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), socketSuffix)
+		if !validNameRe.MatchString(name) {
+			glog.Errorf("Invalid external source name '%s', ignoring...", name)
+			continue
+		}
+		sources = append(sources, Source{
+			name:    name,
+			socket:  filepath.Join(socketDir, file.Name()),
+			timeout: g.config.Timeout.Duration,
+		})
+	}
+	return sources, nil
+}
+
+// Source implements FeatureSource for one external source daemon.
+type Source struct {
+	name    string
+	socket  string
+	timeout time.Duration
+}
+
+func (s Source) Name() string { return s.name }
+
+// Discover connects to the source daemon and asks for its features.
+func (s Source) Discover() (source.Features, error) {
+	timeout := s.timeout
+
+	conn, err := net.DialTimeout("unix", s.socket, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to %s: %v", s.socket, err)
+	}
+	defer conn.Close()
+
+	// The whole exchange must complete within the timeout
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, fmt.Errorf("Failed to set deadline for %s: %v", s.socket, err)
+	}
+
+	err = json.NewEncoder(conn).Encode(Request{APIVersion: APIVersion, Method: "discover"})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request to %s: %v", s.socket, err)
+	}
+
+	resp := Response{}
+	dec := json.NewDecoder(conn)
+	// Keep numbers in their original textual representation
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("Failed to read response from %s: %v", s.socket, err)
+	}
+
+	source.Explainf("reply from %s: %+v", s.socket, resp)
+
+	if resp.APIVersion != APIVersion {
+		return nil, fmt.Errorf("Unsupported protocol version '%s' from %s", resp.APIVersion, s.socket)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	features := source.Features{}
+	for name, raw := range resp.Features {
+		value, err := source.NewValue(raw)
+		if err != nil {
+			glog.Errorf("Invalid value of feature '%s' from %s: %v", name, s.socket, err)
+			continue
+		}
+		features[name] = value
+	}
+	return features, nil
+}
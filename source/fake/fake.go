@@ -21,6 +21,13 @@ import "github.com/kubernetes-incubator/node-feature-discovery/source"
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:      Source{},
+		Description: "Fake features, for testing only",
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "fake" }
 
@@ -28,9 +35,9 @@ func (s Source) Name() string { return "fake" }
 func (s Source) Discover() (source.Features, error) {
 	// Adding three fake features.
 	features := source.Features{
-		"fakefeature1": true,
-		"fakefeature2": true,
-		"fakefeature3": true,
+		"fakefeature1": source.BoolValue(true),
+		"fakefeature2": source.BoolValue(true),
+		"fakefeature3": source.BoolValue(true),
 	}
 
 	return features, nil
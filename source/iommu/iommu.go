@@ -26,6 +26,14 @@ import (
 // Implement FeatureSource interface
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "IOMMU support",
+		DefaultEnabled: true,
+	})
+}
+
 func (s Source) Name() string { return "iommu" }
 
 func (s Source) Discover() (source.Features, error) {
@@ -37,8 +45,9 @@ func (s Source) Discover() (source.Features, error) {
 		return nil, fmt.Errorf("Failed to check for IOMMU support: %v", err)
 	}
 
+	source.Explainf("found %d device(s) in /sys/class/iommu/", len(devices))
 	if len(devices) > 0 {
-		features["enabled"] = true
+		features["enabled"] = source.BoolValue(true)
 	}
 
 	return features, nil
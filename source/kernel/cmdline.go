@@ -0,0 +1,98 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// cmdlineFeatures returns the features of the given kernel command line
+// parameters
+func cmdlineFeatures(names []string, params map[string]string) source.Features {
+	features := source.Features{}
+	for _, name := range names {
+		value, ok := params[paramName(name)]
+		if !ok {
+			source.Explainf("cmdline.%s: not on the kernel command line", name)
+			continue
+		}
+		if validLabelValue(value) {
+			source.Explainf("cmdline.%s: found %s=%s", name, name, value)
+			features["cmdline."+name] = source.StringValue(value)
+		} else {
+			// E.g. lists of CPUs, such as isolcpus=2-7,10
+			source.Explainf("cmdline.%s: found %s=%s, not a valid label value", name, name, value)
+			features["cmdline."+name] = source.BoolValue(true)
+		}
+	}
+	return features
+}
+
+// paramName normalizes the name of a kernel parameter. Dashes and
+// underscores are interchangeable in parameter names.
+func paramName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// parseCmdline parses the kernel command line into parameters and their
+// values, the same way as the kernel does. Whitespace inside double quotes
+// does not separate parameters, and, the quotes are removed. Parameters
+// without a value have the value "true". If a parameter is given multiple
+// times, the last value is used. Parsing stops at "--", after which the
+// arguments are for init.
+func parseCmdline(cmdline string) map[string]string {
+	params := map[string]string{}
+
+	args := []string{}
+	arg := []rune{}
+	inArg, inQuote := false, false
+	for _, c := range cmdline {
+		switch {
+		case c == '"':
+			inArg = true
+			inQuote = !inQuote
+		case unicode.IsSpace(c) && !inQuote:
+			if inArg {
+				args = append(args, string(arg))
+				arg = arg[:0]
+				inArg = false
+			}
+		default:
+			inArg = true
+			arg = append(arg, c)
+		}
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		nameValue := strings.SplitN(arg, "=", 2)
+		if len(nameValue) == 1 {
+			params[paramName(nameValue[0])] = "true"
+		} else {
+			params[paramName(nameValue[0])] = nameValue[1]
+		}
+	}
+	return params
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Operators of match conditions, named after the operators of node affinity
+const (
+	OpIn           = "In"
+	OpNotIn        = "NotIn"
+	OpExists       = "Exists"
+	OpDoesNotExist = "DoesNotExist"
+	OpGt           = "Gt"
+	OpLt           = "Lt"
+)
+
+// Condition is a condition that the value of a kernel setting, e.g. a
+// kconfig option, must match. Without an operator the setting must exist.
+type Condition struct {
+	Op     string   `json:"op,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+func (c *Condition) empty() bool {
+	return c.Op == "" && len(c.Values) == 0
+}
+
+// validate checks that the condition is well-formed
+func (c *Condition) validate() error {
+	switch c.Op {
+	case "", OpExists, OpDoesNotExist:
+		if len(c.Values) != 0 {
+			return fmt.Errorf("no values allowed with operator %q", c.Op)
+		}
+	case OpIn, OpNotIn:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("operator %s requires values", c.Op)
+		}
+	case OpGt, OpLt:
+		if len(c.Values) != 1 {
+			return fmt.Errorf("operator %s requires exactly one value", c.Op)
+		}
+		if _, err := strconv.ParseInt(c.Values[0], 10, 64); err != nil {
+			return fmt.Errorf("operator %s requires an integer value: %v", c.Op, err)
+		}
+	default:
+		return fmt.Errorf("invalid operator %q", c.Op)
+	}
+	return nil
+}
+
+// match tells if a value satisfies the condition. A setting that does not
+// exist has no value (ok is false).
+func (c *Condition) match(value string, ok bool) bool {
+	switch c.Op {
+	case "", OpExists:
+		return ok
+	case OpDoesNotExist:
+		return !ok
+	case OpIn, OpNotIn:
+		in := false
+		for _, v := range c.Values {
+			if ok && v == value {
+				in = true
+				break
+			}
+		}
+		return in == (c.Op == OpIn)
+	case OpGt, OpLt:
+		// Values are decimal, as published in labels. E.g. hexadecimal
+		// kconfig values never match.
+		if !ok {
+			return false
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		// Checked by validate()
+		ref, _ := strconv.ParseInt(c.Values[0], 10, 64)
+		if c.Op == OpGt {
+			return n > ref
+		}
+		return n < ref
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	"github.com/ulikunitz/xz"
+)
+
+// ConfigOpt is a kconfig option to be detected. In the config file it is
+// either the name of the option, or, an object with the fields below.
+type ConfigOpt struct {
+	// Name of the option, without the CONFIG_ prefix
+	Name string `json:"name"`
+	// Publish the value of the option instead of "true"
+	Value bool `json:"value,omitempty"`
+	// Condition that the option must match. By default the option must be
+	// set to 'y' or 'm', or, to any value if Value is set.
+	Condition
+}
+
+// UnmarshalJSON accepts the name of the option as a shorthand
+func (o *ConfigOpt) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*o = ConfigOpt{Name: name}
+		return nil
+	}
+	// Type conversion prevents recursion
+	type configOpt ConfigOpt
+	return json.Unmarshal(data, (*configOpt)(o))
+}
+
+// MarshalJSON uses the shorthand form for options without a condition
+func (o ConfigOpt) MarshalJSON() ([]byte, error) {
+	if !o.Value && o.Condition.empty() {
+		return json.Marshal(o.Name)
+	}
+	type configOpt ConfigOpt
+	return json.Marshal(configOpt(o))
+}
+
+// Validate checks that the condition of the option is well-formed
+func (o *ConfigOpt) Validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("config option without a name")
+	}
+	if err := o.Condition.validate(); err != nil {
+		return fmt.Errorf("config option %s: %v", o.Name, err)
+	}
+	return nil
+}
+
+// match tells if the value of the option satisfies the condition. An unset
+// option has no value (ok is false).
+func (o *ConfigOpt) match(value string, ok bool) bool {
+	if o.Op == "" && !o.Value {
+		return ok && (value == "y" || value == "m")
+	}
+	return o.Condition.match(value, ok)
+}
+
+// kconfigFeatures returns the features of the configured options
+func kconfigFeatures(opts []ConfigOpt, kconfig map[string]string) source.Features {
+	features := source.Features{}
+	for _, opt := range opts {
+		name := "config." + opt.Name
+		value, ok := kconfig[opt.Name]
+		if !opt.match(value, ok) {
+			if ok {
+				source.Explainf("%s: CONFIG_%s=%s does not match the condition", name, opt.Name, value)
+			} else {
+				source.Explainf("%s: CONFIG_%s not set, does not match the condition", name, opt.Name)
+			}
+			continue
+		}
+
+		if !opt.Value {
+			source.Explainf("%s: found CONFIG_%s=%s", name, opt.Name, value)
+			features[name] = source.BoolValue(true)
+		} else if !ok {
+			source.Explainf("%s: CONFIG_%s not set, no value to publish", name, opt.Name)
+		} else if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			source.Explainf("%s: found CONFIG_%s=%s", name, opt.Name, value)
+			features[name] = source.IntValue(n)
+		} else if !validLabelValue(value) {
+			source.Explainf("%s: CONFIG_%s=%s is not a valid label value", name, opt.Name, value)
+			logger.Printf("Value of CONFIG_%s is not a valid label value, ignoring...", opt.Name)
+		} else {
+			source.Explainf("%s: found CONFIG_%s=%s", name, opt.Name, value)
+			features[name] = source.StringValue(value)
+		}
+	}
+	return features
+}
+
+// Placeholder for the kernel release in kconfigPaths
+const releasePlaceholder = "${release}"
+
+// Magic numbers of compressed data
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Markers around the gzipped kconfig embedded in the kernel, or, the configs
+// module (CONFIG_IKCONFIG)
+var (
+	ikconfigStart = []byte("IKCFG_ST")
+	ikconfigEnd   = []byte("IKCFG_ED")
+)
+
+// decompress decompresses gzip or xz compressed data, and, returns other data
+// as such. Zstd compressed data is not supported.
+func decompress(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case bytes.HasPrefix(data, xzMagic):
+		r, err := xz.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case bytes.HasPrefix(data, zstdMagic):
+		return nil, fmt.Errorf("zstd compression is not supported")
+	}
+	return data, nil
+}
+
+// readKconfig reads a kconfig file, which may be compressed, or, extracts the
+// kconfig embedded in a kernel image or module, e.g. configs.ko
+func readKconfig(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = decompress(data); err != nil {
+		return nil, err
+	}
+	if start := bytes.Index(data, ikconfigStart); start >= 0 {
+		data = data[start+len(ikconfigStart):]
+		if end := bytes.Index(data, ikconfigEnd); end >= 0 {
+			data = data[:end]
+		}
+		if data, err = decompress(data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// kconfigCacheEntry is the result of reading a kconfig file, valid as long as
+// the file is not modified
+type kconfigCacheEntry struct {
+	size    int64
+	modTime time.Time
+	data    []byte
+	err     error
+}
+
+// Kconfig files read, by path, so that they are not decompressed, and,
+// failures are not logged, on every discovery round
+var kconfigCache = struct {
+	sync.Mutex
+	entries map[string]kconfigCacheEntry
+}{entries: map[string]kconfigCacheEntry{}}
+
+// readKconfigCached is readKconfig with caching. Tells whether the result
+// was cached, too.
+func readKconfigCached(path string) ([]byte, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	kconfigCache.Lock()
+	defer kconfigCache.Unlock()
+	if e, ok := kconfigCache.entries[path]; ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.data, true, e.err
+	}
+	data, err := readKconfig(path)
+	kconfigCache.entries[path] = kconfigCacheEntry{size: info.Size(), modTime: info.ModTime(), data: data, err: err}
+	return data, false, err
+}
+
+// Read kconfig into a map. The kconfig file specified in the config file is
+// tried first, followed by the kconfig search path. Returns the location of
+// the kconfig used, too.
+func parseKconfig(configFile string, searchPaths []string) (map[string]string, string, error) {
+	candidates := []string{}
+	if len(configFile) > 0 {
+		candidates = append(candidates, configFile)
+	}
+
+	release, err := kernelRelease()
+	if err != nil {
+		logger.Printf("ERROR: Failed to get kernel version: %s", err)
+	}
+	for _, p := range searchPaths {
+		if strings.Contains(p, releasePlaceholder) {
+			if release == "" {
+				continue
+			}
+			p = strings.Replace(p, releasePlaceholder, release, -1)
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			logger.Printf("ERROR: Invalid kconfig path %s: %s", p, err)
+			continue
+		}
+		if matches == nil {
+			source.Explainf("kconfig: %s not found", p)
+		}
+		candidates = append(candidates, matches...)
+	}
+
+	for _, path := range candidates {
+		raw, cached, err := readKconfigCached(path)
+		if err != nil {
+			source.Explainf("kconfig: failed to read %s: %v", path, err)
+			if !os.IsNotExist(err) && !cached {
+				logger.Printf("Failed to read kernel config from %s: %s", path, err)
+			}
+			continue
+		}
+		source.Explainf("using kconfig from %s", path)
+		return parseKconfigData(raw), path, nil
+	}
+	return nil, "", fmt.Errorf("no kernel config found")
+}
+
+// Regexp for matching kconfig options
+var kconfigRe = regexp.MustCompile(`^CONFIG_(?P<flag>\w+)=(?P<value>.*)`)
+
+// parseKconfigData parses the values of all options set in a kconfig file.
+// Options that are not set are left out, and, the quotes around string
+// values are removed.
+func parseKconfigData(raw []byte) map[string]string {
+	kconfig := map[string]string{}
+
+	// Process data, line-by-line
+	lines := bytes.Split(raw, []byte("\n"))
+	for _, line := range lines {
+		if m := kconfigRe.FindStringSubmatch(strings.TrimSpace(string(line))); m != nil {
+			value := m[2]
+			if strings.HasPrefix(value, `"`) {
+				if s, err := strconv.Unquote(value); err == nil {
+					value = s
+				}
+			}
+			kconfig[m[1]] = value
+		}
+	}
+
+	return kconfig
+}
@@ -17,9 +17,7 @@ limitations under the License.
 package kernel
 
 import (
-	"bytes"
-	"compress/gzip"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
 	"regexp"
@@ -31,149 +29,171 @@ import (
 // Configuration file options
 type NFDConfig struct {
 	KconfigFile string
-	ConfigOpts  []string `json:"configOpts,omitempty"`
+	// Locations to search for the kconfig, in order, after KconfigFile
+	KconfigPaths []string    `json:"kconfigPaths,omitempty"`
+	ConfigOpts   []ConfigOpt `json:"configOpts,omitempty"`
+	// Kernel modules to detect
+	Modules []string `json:"modules,omitempty"`
+	// Kernel command line parameters to detect
+	CmdlineParams []string `json:"cmdlineParams,omitempty"`
+	// Sysctls to detect
+	Sysctls []Sysctl `json:"sysctls,omitempty"`
+	// Kernel versions to compare the running kernel against
+	VersionThresholds []string `json:"versionThresholds,omitempty"`
 }
 
 var logger = log.New(os.Stderr, "", log.LstdFlags)
 
-var Config = NFDConfig{
-	KconfigFile: "",
-	ConfigOpts: []string{
-		"NO_HZ",
-		"NO_HZ_IDLE",
-		"NO_HZ_FULL",
-		"PREEMPT",
-	},
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *NFDConfig {
+	return &NFDConfig{
+		KconfigFile: "",
+		KconfigPaths: []string{
+			"/proc/config.gz",
+			source.HostBootDir + "/config-" + releasePlaceholder,
+			source.HostLibModulesDir + "/" + releasePlaceholder + "/config",
+			source.HostUsrLibModulesDir + "/" + releasePlaceholder + "/config",
+			source.HostLibModulesDir + "/" + releasePlaceholder + "/kernel/kernel/configs.ko*",
+			source.HostUsrLibModulesDir + "/" + releasePlaceholder + "/kernel/kernel/configs.ko*",
+		},
+		ConfigOpts: []ConfigOpt{
+			{Name: "NO_HZ"},
+			{Name: "NO_HZ_IDLE"},
+			{Name: "NO_HZ_FULL"},
+			{Name: "PREEMPT"},
+		},
+	}
+}
+
+// Validate checks the conditions of configOpts and sysctls, and, the version
+// thresholds
+func (c *NFDConfig) Validate() error {
+	for i := range c.ConfigOpts {
+		if err := c.ConfigOpts[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range c.Sysctls {
+		if err := c.Sysctls[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for _, t := range c.VersionThresholds {
+		if !versionThresholdRe.MatchString(t) {
+			return fmt.Errorf("invalid version threshold %q, must be in the form of MAJOR[.MINOR[.PATCH]]", t)
+		}
+	}
+	return nil
+}
+
+// Implement FeatureSource and ConfigurableSource interfaces
+type Source struct {
+	config *NFDConfig
 }
 
-// Implement FeatureSource interface
-type Source struct{}
+func init() {
+	source.Register(source.Registration{
+		Source:         &Source{config: newDefaultConfig()},
+		Description:    "Kernel version, configuration options and runtime state",
+		DefaultEnabled: true,
+	})
+}
+
+func (s *Source) Name() string { return "kernel" }
+
+// NewConfig method of the ConfigurableSource interface
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
-func (s Source) Name() string { return "kernel" }
+// GetConfig method of the ConfigurableSource interface
+func (s *Source) GetConfig() source.Config { return s.config }
 
-func (s Source) Discover() (source.Features, error) {
+// SetConfig method of the ConfigurableSource interface
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *NFDConfig:
+		s.config = v
+	default:
+		panic(fmt.Sprintf("invalid config type: %T", conf))
+	}
+}
+
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
 	// Read kernel version
-	version, err := parseVersion()
+	release, err := kernelRelease()
 	if err != nil {
 		logger.Printf("ERROR: Failed to get kernel version: %s", err)
 	} else {
-		for key := range version {
-			features["version."+key] = version[key]
+		for name, value := range versionFeatures(release, s.config.VersionThresholds) {
+			features[name] = value
 		}
 	}
 
 	// Read kconfig
-	kconfig, err := parseKconfig()
+	kconfig, location, err := parseKconfig(s.config.KconfigFile, s.config.KconfigPaths)
 	if err != nil {
 		logger.Printf("ERROR: Failed to read kconfig: %s", err)
-	}
+	} else {
+		features["kconfig.location"] = source.StringValue(labelSafe(location))
 
-	// Check flags
-	for _, opt := range Config.ConfigOpts {
-		if _, ok := kconfig[opt]; ok {
-			features["config."+opt] = true
+		// Check flags
+		for name, value := range kconfigFeatures(s.config.ConfigOpts, kconfig) {
+			features[name] = value
 		}
 	}
 
-	return features, nil
-}
-
-// Read and parse kernel version
-func parseVersion() (map[string]string, error) {
-	version := map[string]string{}
-
-	// Open file for reading
-	raw, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
-	if err != nil {
-		return nil, err
+	// Read kernel modules
+	if len(s.config.Modules) > 0 {
+		for name, value := range moduleFeatures(s.config.Modules) {
+			features[name] = value
+		}
 	}
 
-	full := strings.TrimSpace(string(raw))
-	version["full"] = full
-
-	// Regexp for parsing version components
-	re := regexp.MustCompile(`^(?P<major>\d+)(\.(?P<minor>\d+))?(\.(?P<revision>\d+))?(-.*)?$`)
-	if m := re.FindStringSubmatch(full); m != nil {
-		for i, name := range re.SubexpNames() {
-			if i != 0 && name != "" {
-				version[name] = m[i]
+	// Read kernel command line
+	if len(s.config.CmdlineParams) > 0 {
+		raw, err := source.ReadFile("/proc/cmdline")
+		if err != nil {
+			logger.Printf("ERROR: Failed to read kernel command line: %s", err)
+		} else {
+			for name, value := range cmdlineFeatures(s.config.CmdlineParams, parseCmdline(string(raw))) {
+				features[name] = value
 			}
 		}
 	}
 
-	return version, nil
-}
-
-// Read gzipped kernel config
-func readKconfigGzip(filename string) ([]byte, error) {
-	// Open file for reading
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+	// Read sysctls
+	for name, value := range sysctlFeatures(s.config.Sysctls, "/proc/sys") {
+		features[name] = value
 	}
-	defer f.Close()
 
-	// Uncompress data
-	r, err := gzip.NewReader(f)
-	if err != nil {
-		return nil, err
+	// Read taint, livepatches and real-time support
+	for name, value := range taintFeatures("/proc/sys") {
+		features[name] = value
 	}
-	defer r.Close()
-
-	return ioutil.ReadAll(r)
-}
-
-// Read kconfig into a map
-func parseKconfig() (map[string]bool, error) {
-	kconfig := map[string]bool{}
-	raw := []byte(nil)
-	err := error(nil)
-
-	// First, try kconfig specified in the config file
-	if len(Config.KconfigFile) > 0 {
-		raw, err = ioutil.ReadFile(Config.KconfigFile)
-		if err != nil {
-			logger.Printf("ERROR: Failed to read kernel config from %s: %s", Config.KconfigFile, err)
-		}
+	for name, value := range livepatchFeatures(source.HostSysDir) {
+		features[name] = value
 	}
-
-	// Then, try to read from /proc
-	if raw == nil {
-		raw, err = readKconfigGzip("/proc/config.gz")
-		if err != nil {
-			logger.Printf("Failed to read /proc/config.gz: %s", err)
-		}
+	for name, value := range realtimeFeatures(source.HostSysDir, kconfig) {
+		features[name] = value
 	}
 
-	// Last, try to read from /boot/
-	if raw == nil {
-		// Get kernel version
-		unameRaw, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
-		uname := strings.TrimSpace(string(unameRaw))
-		if err != nil {
-			return nil, err
-		}
-		// Read kconfig
-		raw, err = ioutil.ReadFile("/host-boot/config-" + uname)
-		if err != nil {
-			return nil, err
-		}
-	}
+	return features, nil
+}
 
-	// Regexp for matching kconfig flags
-	re := regexp.MustCompile(`^CONFIG_(?P<flag>\w+)=(?P<value>.+)`)
+// Regexp for valid label values
+var labelValueRe = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
 
-	// Process data, line-by-line
-	lines := bytes.Split(raw, []byte("\n"))
-	for _, line := range lines {
-		if m := re.FindStringSubmatch(string(line)); m != nil {
-			if m[2] == "y" || m[2] == "m" {
-				kconfig[m[1]] = true
-			}
-		}
-	}
+// validLabelValue tells if value can be used as a label value as such
+func validLabelValue(value string) bool {
+	return len(value) <= 63 && labelValueRe.MatchString(value)
+}
 
-	return kconfig, nil
+// kernelRelease returns the release of the running kernel, e.g. 4.5.6-7-generic
+func kernelRelease() (string, error) {
+	raw, err := source.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
 }
@@ -0,0 +1,373 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/ulikunitz/xz"
+)
+
+const testKconfig = `#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_X86=y
+CONFIG_KVM=m
+# CONFIG_PREEMPT is not set
+CONFIG_HZ=1000
+CONFIG_NR_CPUS=8192
+CONFIG_PHYSICAL_START=0x1000000
+CONFIG_DEFAULT_HOSTNAME="(none)"
+CONFIG_DEFAULT_TCP_CONG="cubic"
+`
+
+func TestKconfig(t *testing.T) {
+	Convey("When parsing kconfig", t, func() {
+		kconfig := parseKconfigData([]byte(testKconfig))
+
+		Convey("All options set are parsed with their values", func() {
+			So(kconfig, ShouldResemble, map[string]string{
+				"X86":              "y",
+				"KVM":              "m",
+				"HZ":               "1000",
+				"NR_CPUS":          "8192",
+				"PHYSICAL_START":   "0x1000000",
+				"DEFAULT_HOSTNAME": "(none)",
+				"DEFAULT_TCP_CONG": "cubic",
+			})
+		})
+
+		Convey("Options are matched against their conditions", func() {
+			opts := []ConfigOpt{
+				{Name: "X86"},
+				{Name: "KVM"},
+				{Name: "PREEMPT"},
+				{Name: "HZ"},
+				{Name: "HZ", Value: true, Condition: Condition{Op: OpIn, Values: []string{"250", "1000"}}},
+				{Name: "KVM", Value: true},
+				{Name: "DEFAULT_TCP_CONG", Value: true},
+				{Name: "DEFAULT_HOSTNAME", Value: true},
+				{Name: "NR_CPUS", Condition: Condition{Op: OpGt, Values: []string{"255"}}},
+				{Name: "PHYSICAL_START", Condition: Condition{Op: OpGt, Values: []string{"0"}}},
+				{Name: "PREEMPT_RT", Condition: Condition{Op: OpDoesNotExist}},
+			}
+			for i := range opts {
+				So(opts[i].Validate(), ShouldBeNil)
+			}
+
+			So(kconfigFeatures(opts, kconfig), ShouldResemble, source.Features{
+				"config.X86":              source.BoolValue(true),
+				"config.KVM":              source.StringValue("m"),
+				"config.HZ":               source.IntValue(1000),
+				"config.DEFAULT_TCP_CONG": source.StringValue("cubic"),
+				"config.NR_CPUS":          source.BoolValue(true),
+				"config.PREEMPT_RT":       source.BoolValue(true),
+			})
+		})
+
+		Convey("Invalid conditions are rejected", func() {
+			So((&ConfigOpt{Name: "HZ", Condition: Condition{Op: OpIn}}).Validate(), ShouldNotBeNil)
+			So((&ConfigOpt{Name: "HZ", Condition: Condition{Op: OpGt, Values: []string{"high"}}}).Validate(), ShouldNotBeNil)
+			So((&ConfigOpt{Name: "HZ", Condition: Condition{Op: OpGt, Values: []string{"0x100"}}}).Validate(), ShouldNotBeNil)
+			So((&ConfigOpt{Name: "HZ", Condition: Condition{Op: "Equals", Values: []string{"1000"}}}).Validate(), ShouldNotBeNil)
+		})
+	})
+}
+
+func gzipData(data []byte) []byte {
+	buf := bytes.Buffer{}
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func xzData(data []byte) []byte {
+	buf := bytes.Buffer{}
+	w, err := xz.NewWriter(&buf)
+	So(err, ShouldBeNil)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestKconfigSearch(t *testing.T) {
+	Convey("When searching for kconfig", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-kernel-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		release, err := kernelRelease()
+		So(err, ShouldBeNil)
+		expected := parseKconfigData([]byte(testKconfig))
+
+		Convey("Compressed kconfig is found with the release in the path", func() {
+			path := filepath.Join(dir, "config-"+release+"-abc123")
+			So(ioutil.WriteFile(path, gzipData([]byte(testKconfig)), 0644), ShouldBeNil)
+
+			kconfig, location, err := parseKconfig("", []string{
+				filepath.Join(dir, "missing"),
+				filepath.Join(dir, "config-${release}*"),
+			})
+			So(err, ShouldBeNil)
+			So(location, ShouldEqual, path)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("Kconfig is extracted from the configs module", func() {
+			module := append([]byte("\x7fELF...IKCFG_ST"), gzipData([]byte(testKconfig))...)
+			module = append(module, []byte("IKCFG_ED...")...)
+			path := filepath.Join(dir, "configs.ko.xz")
+			So(ioutil.WriteFile(path, xzData(module), 0644), ShouldBeNil)
+
+			kconfig, location, err := parseKconfig("", []string{filepath.Join(dir, "configs.ko*")})
+			So(err, ShouldBeNil)
+			So(location, ShouldEqual, path)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("Zstd compressed kconfig is skipped", func() {
+			zstdPath := filepath.Join(dir, "config.zst")
+			So(ioutil.WriteFile(zstdPath, append(zstdMagic, "..."...), 0644), ShouldBeNil)
+			path := filepath.Join(dir, "config")
+			So(ioutil.WriteFile(path, []byte(testKconfig), 0644), ShouldBeNil)
+
+			kconfig, location, err := parseKconfig("", []string{zstdPath, path})
+			So(err, ShouldBeNil)
+			So(location, ShouldEqual, path)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("Kconfig is read again only when modified", func() {
+			path := filepath.Join(dir, "config")
+			So(ioutil.WriteFile(path, []byte(testKconfig), 0644), ShouldBeNil)
+			_, cached, err := readKconfigCached(path)
+			So(err, ShouldBeNil)
+			So(cached, ShouldBeFalse)
+			_, cached, err = readKconfigCached(path)
+			So(err, ShouldBeNil)
+			So(cached, ShouldBeTrue)
+
+			So(ioutil.WriteFile(path, []byte("CONFIG_X86=y\n"), 0644), ShouldBeNil)
+			data, cached, err := readKconfigCached(path)
+			So(err, ShouldBeNil)
+			So(cached, ShouldBeFalse)
+			So(string(data), ShouldEqual, "CONFIG_X86=y\n")
+		})
+
+		Convey("No kconfig found is an error", func() {
+			_, _, err := parseKconfig(filepath.Join(dir, "missing"), []string{filepath.Join(dir, "*")})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestModules(t *testing.T) {
+	Convey("When reading kernel modules", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-kernel-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		ioutil.WriteFile(filepath.Join(dir, "modules"), []byte(
+			"kvm_intel 241664 0 - Live 0x0000000000000000\n"+
+				"kvm 737280 1 kvm_intel, Live 0x0000000000000000\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "modules.dep"), []byte(
+			"kernel/arch/x86/kvm/kvm.ko.xz:\n"+
+				"kernel/arch/x86/kvm/kvm-intel.ko.xz: kernel/arch/x86/kvm/kvm.ko.xz\n"+
+				"kernel/drivers/vfio/pci/vfio-pci.ko.xz: kernel/drivers/vfio/vfio.ko.xz\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "modules.builtin"), []byte(
+			"kernel/drivers/nvme/host/nvme.ko\n"), 0644)
+
+		modules, err := readModules(filepath.Join(dir, "modules"), dir)
+		So(err, ShouldBeNil)
+		So(modules, ShouldResemble, map[string]string{
+			"kvm":       "loaded",
+			"kvm_intel": "loaded",
+			"vfio_pci":  "available",
+			"nvme":      "builtin",
+		})
+		So(modules[moduleName("vfio-pci")], ShouldEqual, "available")
+
+		_, err = readModules(filepath.Join(dir, "missing"), filepath.Join(dir, "missing"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCmdline(t *testing.T) {
+	Convey("When parsing the kernel command line", t, func() {
+		params := parseCmdline(`BOOT_IMAGE=/vmlinuz-4.19.0 root=UUID=1234 ro quiet isolcpus=2-7,10 ` +
+			`nohz_full=2-7 default_hugepagesz=1G intel_iommu=off intel_iommu=on dyndbg="file foo.c +p" ` +
+			"mitigations=off\tkvm-intel.nested=1 -- single init_arg=1\n")
+
+		So(params, ShouldResemble, map[string]string{
+			"BOOT_IMAGE":         "/vmlinuz-4.19.0",
+			"root":               "UUID=1234",
+			"ro":                 "true",
+			"quiet":              "true",
+			"isolcpus":           "2-7,10",
+			"nohz_full":          "2-7",
+			"default_hugepagesz": "1G",
+			"intel_iommu":        "on",
+			"dyndbg":             "file foo.c +p",
+			"mitigations":        "off",
+			"kvm_intel.nested":   "1",
+		})
+
+		features := cmdlineFeatures([]string{"isolcpus", "intel_iommu", "kvm-intel.nested", "nosmt", "quiet"}, params)
+		So(features, ShouldResemble, source.Features{
+			"cmdline.isolcpus":         source.BoolValue(true),
+			"cmdline.intel_iommu":      source.StringValue("on"),
+			"cmdline.kvm-intel.nested": source.StringValue("1"),
+			"cmdline.quiet":            source.StringValue("true"),
+		})
+	})
+}
+
+func TestSysctls(t *testing.T) {
+	Convey("When reading sysctls", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-kernel-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		for path, value := range map[string]string{
+			"kernel/sched_rt_runtime_us":       "-1\n",
+			"net/core/rmem_max":                "212992\n",
+			"net/ipv4/tcp_rmem":                "4096\t131072\t6291456\n",
+			"net/ipv4/tcp_congestion_control":  "bbr\n",
+			"net/ipv4/conf/eth0.100/rp_filter": "1\n",
+			"vm/overcommit_memory":             "1\n",
+			"kernel/panic":                     "010\n",
+			"kernel/core_pattern":              "|/usr/lib/systemd/systemd-coredump %P\n",
+		} {
+			So(os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(dir, path), []byte(value), 0644), ShouldBeNil)
+		}
+
+		sysctls := []Sysctl{
+			{Key: "kernel.sched_rt_runtime_us"},
+			{Key: "net.ipv4.tcp_congestion_control"},
+			{Key: "net/ipv4/conf/eth0.100/rp_filter"},
+			{Key: "net.ipv4.tcp_rmem"},
+			{Key: "kernel.core_pattern"},
+			{Key: "kernel.no_such_sysctl"},
+			{Key: "net.core.rmem_max", Condition: Condition{Op: OpGt, Values: []string{"131071"}}},
+			// Zero-padded values are decimal, too
+			{Key: "kernel.panic", Condition: Condition{Op: OpGt, Values: []string{"9"}}},
+			{Key: "vm.overcommit_memory", Condition: Condition{Op: OpIn, Values: []string{"0", "2"}}},
+			{Key: "net.ipv4.tcp_rmem", Condition: Condition{Op: OpIn, Values: []string{"4096 131072 6291456"}}},
+		}
+		for i := range sysctls {
+			So(sysctls[i].Validate(), ShouldBeNil)
+		}
+		So((&Sysctl{Key: "../../etc/shadow"}).Validate(), ShouldNotBeNil)
+
+		So(sysctlFeatures(sysctls, dir), ShouldResemble, source.Features{
+			"sysctl.kernel.sched_rt_runtime_us":       source.IntValue(-1),
+			"sysctl.net.ipv4.tcp_congestion_control":  source.StringValue("bbr"),
+			"sysctl.net.ipv4.conf.eth0.100.rp_filter": source.IntValue(1),
+			"sysctl.net.core.rmem_max":                source.BoolValue(true),
+			"sysctl.kernel.panic":                     source.BoolValue(true),
+			"sysctl.net.ipv4.tcp_rmem":                source.BoolValue(true),
+		})
+	})
+}
+
+func TestVersion(t *testing.T) {
+	Convey("When parsing the kernel version", t, func() {
+		thresholds := []string{"3", "3.10", "4.19", "5.0"}
+
+		So(versionFeatures("3.10.0-1160.el7.x86_64", thresholds), ShouldResemble, source.Features{
+			"version.full":     source.StringValue("3.10.0-1160.el7.x86_64"),
+			"version.major":    source.IntValue(3),
+			"version.minor":    source.IntValue(10),
+			"version.revision": source.IntValue(0),
+			"version.code":     source.IntValue(3010000),
+			"version.gte-3":    source.BoolValue(true),
+			"version.gte-3.10": source.BoolValue(true),
+		})
+		So(versionFeatures("5.0.0-rc1+", thresholds), ShouldResemble, source.Features{
+			"version.full":     source.StringValue("5.0.0-rc1"),
+			"version.major":    source.IntValue(5),
+			"version.minor":    source.IntValue(0),
+			"version.revision": source.IntValue(0),
+			"version.code":     source.IntValue(5000000),
+			"version.gte-3":    source.BoolValue(true),
+			"version.gte-3.10": source.BoolValue(true),
+			"version.gte-4.19": source.BoolValue(true),
+		})
+		So(versionFeatures("4.19+", nil), ShouldResemble, source.Features{
+			"version.full":  source.StringValue("4.19"),
+			"version.major": source.IntValue(4),
+			"version.minor": source.IntValue(19),
+			"version.code":  source.IntValue(4019000),
+		})
+	})
+}
+
+func TestState(t *testing.T) {
+	Convey("When reading the state of the kernel", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-kernel-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		for path, value := range map[string]string{
+			"proc/sys/kernel/tainted":                  "45057\n",
+			"sys/kernel/livepatch/livepatch_1/enabled": "1\n",
+			"sys/kernel/livepatch/livepatch_2/enabled": "0\n",
+		} {
+			So(os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(dir, path), []byte(value), 0644), ShouldBeNil)
+		}
+		sysDir := filepath.Join(dir, "sys")
+
+		Convey("Taint flags are decoded", func() {
+			// Bits 0, 12, 13 and 15
+			So(taintFeatures(filepath.Join(dir, "proc/sys")), ShouldResemble, source.Features{
+				"tainted": source.BoolValue(true),
+				"taint": source.ListValue{
+					source.StringValue("proprietary_module"),
+					source.StringValue("oot_module"),
+					source.StringValue("unsigned_module"),
+					source.StringValue("livepatch"),
+				},
+			})
+		})
+
+		Convey("Enabled livepatches are detected", func() {
+			So(livepatchFeatures(sysDir), ShouldResemble, source.Features{
+				"livepatch": source.ListValue{source.StringValue("livepatch_1")},
+			})
+		})
+
+		Convey("Real-time kernels are detected", func() {
+			So(realtimeFeatures(sysDir, nil), ShouldResemble, source.Features{})
+			So(realtimeFeatures(sysDir, map[string]string{"PREEMPT_RT": "y"}), ShouldResemble, source.Features{
+				"realtime": source.BoolValue(true),
+			})
+			So(ioutil.WriteFile(filepath.Join(sysDir, "kernel/realtime"), []byte("1\n"), 0644), ShouldBeNil)
+			So(realtimeFeatures(sysDir, nil), ShouldResemble, source.Features{
+				"realtime": source.BoolValue(true),
+			})
+		})
+	})
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// States of kernel modules, in the order of precedence
+const (
+	moduleBuiltin   = "builtin"
+	moduleLoaded    = "loaded"
+	moduleAvailable = "available"
+)
+
+// moduleFeatures returns the states of the given kernel modules
+func moduleFeatures(names []string) source.Features {
+	features := source.Features{}
+
+	release, err := kernelRelease()
+	if err != nil {
+		logger.Printf("ERROR: Failed to get kernel version: %s", err)
+		return features
+	}
+	modules, err := readModules("/proc/modules", filepath.Join(source.HostLibModulesDir, release))
+	if err != nil {
+		logger.Printf("ERROR: Failed to read kernel modules: %s", err)
+		return features
+	}
+
+	for _, name := range names {
+		if state, ok := modules[moduleName(name)]; ok {
+			source.Explainf("module.%s: %s", name, state)
+			features["module."+name] = source.StringValue(state)
+		} else {
+			source.Explainf("module.%s: not found", name)
+		}
+	}
+	return features
+}
+
+// moduleName normalizes the name of a kernel module. Dashes and underscores
+// are interchangeable in module names, the kernel uses underscores.
+func moduleName(name string) string {
+	return strings.Replace(name, "-", "_", -1)
+}
+
+// readModules returns the states of all kernel modules known on the node,
+// based on the list of loaded modules (/proc/modules) and the modules.builtin
+// and modules.dep files of the kernel in modulesDir
+func readModules(procModules string, modulesDir string) (map[string]string, error) {
+	modules := map[string]string{}
+	failed := 0
+
+	// Available modules, with paths in the form of
+	// "kernel/drivers/vfio/pci/vfio-pci.ko.xz: <dependencies>"
+	if raw, err := source.ReadFile(filepath.Join(modulesDir, "modules.dep")); err != nil {
+		logger.Printf("Failed to read available kernel modules: %s", err)
+		failed++
+	} else {
+		for _, line := range bytes.Split(raw, []byte("\n")) {
+			if i := bytes.IndexByte(line, ':'); i > 0 {
+				modules[moduleFileName(string(line[:i]))] = moduleAvailable
+			}
+		}
+	}
+
+	// Loaded modules, the name being the first field of each line
+	if raw, err := source.ReadFile(procModules); err != nil {
+		logger.Printf("Failed to read loaded kernel modules: %s", err)
+		failed++
+	} else {
+		for _, line := range bytes.Split(raw, []byte("\n")) {
+			if fields := strings.Fields(string(line)); len(fields) > 0 {
+				modules[moduleName(fields[0])] = moduleLoaded
+			}
+		}
+	}
+
+	// Built-in modules, one path per line
+	if raw, err := source.ReadFile(filepath.Join(modulesDir, "modules.builtin")); err != nil {
+		logger.Printf("Failed to read built-in kernel modules: %s", err)
+		failed++
+	} else {
+		for _, line := range bytes.Split(raw, []byte("\n")) {
+			if p := strings.TrimSpace(string(line)); p != "" {
+				modules[moduleFileName(p)] = moduleBuiltin
+			}
+		}
+	}
+
+	if failed == 3 {
+		return nil, fmt.Errorf("no kernel module information available")
+	}
+	return modules, nil
+}
+
+// moduleFileName returns the name of the kernel module in the file at p,
+// which may be compressed
+func moduleFileName(p string) string {
+	name := path.Base(p)
+	if i := strings.Index(name, ".ko"); i > 0 {
+		name = name[:i]
+	}
+	return moduleName(name)
+}
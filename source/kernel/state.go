@@ -0,0 +1,142 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Names of the taint flags, indexed by bit number, as in
+// Documentation/admin-guide/tainted-kernels.rst
+var taintFlags = []string{
+	"proprietary_module",
+	"forced_module",
+	"cpu_out_of_spec",
+	"forced_rmmod",
+	"machine_check",
+	"bad_page",
+	"user",
+	"die",
+	"overridden_acpi_table",
+	"warn",
+	"crap",
+	"firmware_workaround",
+	"oot_module",
+	"unsigned_module",
+	"softlockup",
+	"livepatch",
+	"aux",
+	"randstruct",
+	"test",
+	"fwctl",
+}
+
+// taintFeatures returns the taint flags of the kernel, read from the
+// kernel.tainted sysctl under procSys
+func taintFeatures(procSys string) source.Features {
+	features := source.Features{}
+
+	raw, err := source.ReadFile(filepath.Join(procSys, "kernel/tainted"))
+	if err != nil {
+		logger.Printf("ERROR: Failed to read kernel taint: %s", err)
+		return features
+	}
+	tainted, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		logger.Printf("ERROR: Failed to parse kernel taint: %s", err)
+		return features
+	}
+	if tainted == 0 {
+		source.Explainf("tainted: kernel is not tainted")
+		return features
+	}
+
+	flags := source.ListValue{}
+	for bit := uint(0); bit < 64; bit++ {
+		if tainted&(1<<bit) == 0 {
+			continue
+		}
+		// Flags added by later kernels are published by their bit number
+		name := "bit" + strconv.Itoa(int(bit))
+		if int(bit) < len(taintFlags) {
+			name = taintFlags[bit]
+		}
+		source.Explainf("taint.%s: bit %d of %d is set", name, bit, tainted)
+		flags = append(flags, source.StringValue(name))
+	}
+	features["tainted"] = source.BoolValue(true)
+	features["taint"] = flags
+	return features
+}
+
+// livepatchFeatures returns the livepatches that are enabled, according to
+// the sysfs at sysDir
+func livepatchFeatures(sysDir string) source.Features {
+	features := source.Features{}
+
+	// Only exists if the kernel supports livepatching
+	dir := filepath.Join(sysDir, "kernel/livepatch")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		source.Explainf("livepatch: %v", err)
+		return features
+	}
+
+	patches := source.ListValue{}
+	for _, e := range entries {
+		raw, err := source.ReadFile(filepath.Join(dir, e.Name(), "enabled"))
+		if err != nil || strings.TrimSpace(string(raw)) != "1" {
+			continue
+		}
+		// Livepatches are kernel modules, whose names are valid in labels
+		if !validLabelValue(e.Name()) {
+			logger.Printf("Name of livepatch %s is not valid in labels, ignoring...", e.Name())
+			continue
+		}
+		patches = append(patches, source.StringValue(e.Name()))
+	}
+	if len(patches) > 0 {
+		features["livepatch"] = patches
+	}
+	return features
+}
+
+// realtimeFeatures tells if the kernel is a real-time (PREEMPT_RT) kernel,
+// according to the sysfs at sysDir or the kconfig, which may be nil
+func realtimeFeatures(sysDir string, kconfig map[string]string) source.Features {
+	features := source.Features{}
+
+	// Added by the real-time patch set
+	if raw, err := source.ReadFile(filepath.Join(sysDir, "kernel/realtime")); err == nil && strings.TrimSpace(string(raw)) == "1" {
+		features["realtime"] = source.BoolValue(true)
+		return features
+	}
+	// PREEMPT_RT_FULL in the real-time patch set of kernels before 5.3
+	for _, name := range []string{"PREEMPT_RT", "PREEMPT_RT_FULL"} {
+		if kconfig[name] == "y" {
+			source.Explainf("realtime: CONFIG_%s=y", name)
+			features["realtime"] = source.BoolValue(true)
+			return features
+		}
+	}
+	return features
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Sysctl is a sysctl to be detected. In the config file it is either the
+// key of the sysctl, or, an object with the fields below.
+type Sysctl struct {
+	// Key of the sysctl, e.g. vm.overcommit_memory
+	Key string `json:"key"`
+	// Condition that the value must match. Without an operator the value
+	// of the sysctl is published, otherwise "true" if the condition is
+	// matched.
+	Condition
+}
+
+// UnmarshalJSON accepts the key of the sysctl as a shorthand
+func (s *Sysctl) UnmarshalJSON(data []byte) error {
+	var key string
+	if err := json.Unmarshal(data, &key); err == nil {
+		*s = Sysctl{Key: key}
+		return nil
+	}
+	// Type conversion prevents recursion
+	type sysctl Sysctl
+	return json.Unmarshal(data, (*sysctl)(s))
+}
+
+// MarshalJSON uses the shorthand form for sysctls without a condition
+func (s Sysctl) MarshalJSON() ([]byte, error) {
+	if s.Condition.empty() {
+		return json.Marshal(s.Key)
+	}
+	type sysctl Sysctl
+	return json.Marshal(sysctl(s))
+}
+
+// Regexp for valid sysctl keys, separated either with dots or slashes
+var sysctlKeyRe = regexp.MustCompile(`^[\w-]+([./][\w-]+)*$`)
+
+// Validate checks the key and the condition of the sysctl
+func (s *Sysctl) Validate() error {
+	if !sysctlKeyRe.MatchString(s.Key) {
+		return fmt.Errorf("invalid sysctl key %q", s.Key)
+	}
+	if err := s.Condition.validate(); err != nil {
+		return fmt.Errorf("sysctl %s: %v", s.Key, err)
+	}
+	return nil
+}
+
+// sysctlPath returns the path of a sysctl under /proc/sys. Keys separated
+// with slashes are used as such, in order to support dots in the components,
+// e.g. net/ipv4/conf/eth0.100/rp_filter.
+func sysctlPath(procSys string, key string) string {
+	if !strings.Contains(key, "/") {
+		key = strings.Replace(key, ".", "/", -1)
+	}
+	return filepath.Join(procSys, key)
+}
+
+// sysctlFeatures returns the features of the given sysctls
+func sysctlFeatures(sysctls []Sysctl, procSys string) source.Features {
+	features := source.Features{}
+	for _, s := range sysctls {
+		// Slashes are not allowed in label names
+		name := "sysctl." + strings.Replace(s.Key, "/", ".", -1)
+		raw, err := source.ReadFile(sysctlPath(procSys, s.Key))
+		if err != nil && !os.IsNotExist(err) {
+			logger.Printf("ERROR: Failed to read sysctl %s: %s", s.Key, err)
+			continue
+		}
+		ok := err == nil
+		// Values of multiple fields, e.g. net.ipv4.tcp_rmem, are separated
+		// with tabs or spaces
+		value := strings.Join(strings.Fields(string(raw)), " ")
+
+		if s.Op != "" {
+			if s.match(value, ok) {
+				source.Explainf("%s: %s=%q matches the condition", name, s.Key, value)
+				features[name] = source.BoolValue(true)
+			} else {
+				source.Explainf("%s: %s=%q does not match the condition", name, s.Key, value)
+			}
+		} else if !ok {
+			source.Explainf("%s: no such sysctl", name)
+		} else if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			features[name] = source.IntValue(n)
+		} else if !validLabelValue(value) {
+			source.Explainf("%s: %s=%q is not a valid label value", name, s.Key, value)
+			logger.Printf("Value of sysctl %s is not a valid label value, ignoring...", s.Key)
+		} else {
+			features[name] = source.StringValue(value)
+		}
+	}
+	return features
+}
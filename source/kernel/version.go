@@ -0,0 +1,100 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Regexp for parsing kernel version components. The suffix, e.g.
+// "-1160.el7.x86_64", "+" or "_custom", is not a part of the version.
+var versionRe = regexp.MustCompile(`^(?P<major>\d+)(\.(?P<minor>\d+))?(\.(?P<revision>\d+))?(?P<suffix>[-+_.~].*)?$`)
+
+// Regexp for release candidates in the version suffix
+var rcRe = regexp.MustCompile(`^-(rc\d+)`)
+
+// Regexp for version thresholds in the config
+var versionThresholdRe = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+
+// Regexp for characters not allowed in label values
+var invalidLabelCharsRe = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// versionFeatures returns the features of the kernel release, including
+// whether it is at least the given threshold versions
+func versionFeatures(release string, thresholds []string) source.Features {
+	features := source.Features{
+		"version.full": source.StringValue(labelSafe(release)),
+	}
+
+	m := versionRe.FindStringSubmatch(release)
+	if m == nil {
+		source.Explainf("version: unable to parse kernel version %q", release)
+		return features
+	}
+	components := map[string]string{}
+	for i, name := range versionRe.SubexpNames() {
+		if i != 0 && name != "" {
+			components[name] = m[i]
+		}
+	}
+
+	// Missing components are zero
+	v := source.VersionValue{}
+	for name, p := range map[string]*int{"major": &v.Major, "minor": &v.Minor, "revision": &v.Patch} {
+		if n, err := strconv.Atoi(components[name]); err == nil {
+			*p = n
+			features["version."+name] = source.IntValue(n)
+		}
+	}
+	// Comparable with the Gt and Lt operators of node affinity, e.g.
+	// 4019000 for 4.19.0
+	features["version.code"] = source.IntValue(v.Major*1000000 + v.Minor*1000 + v.Patch)
+
+	// Distribution suffixes do not make a kernel older, unlike release
+	// candidates
+	if rc := rcRe.FindStringSubmatch(components["suffix"]); rc != nil {
+		v.Pre = rc[1]
+	}
+	for _, t := range thresholds {
+		tv, err := source.ParseVersion(t)
+		if err != nil {
+			// Checked by NFDConfig.Validate()
+			continue
+		}
+		if v.Compare(tv) >= 0 {
+			source.Explainf("version.gte-%s: kernel version %v >= %v", t, v, tv)
+			features["version.gte-"+t] = source.BoolValue(true)
+		} else {
+			source.Explainf("version.gte-%s: kernel version %v < %v", t, v, tv)
+		}
+	}
+	return features
+}
+
+// labelSafe turns s into a valid label value by replacing invalid characters
+// with underscores and trimming the result
+func labelSafe(s string) string {
+	s = invalidLabelCharsRe.ReplaceAllString(s, "_")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-_.")
+}
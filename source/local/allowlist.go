@@ -0,0 +1,82 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var sha256Re = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// hookDigest returns the hex encoded SHA-256 digest of the file at path
+func hookDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return fileDigest(f)
+}
+
+// fileDigest returns the hex encoded SHA-256 digest of the contents of f
+func fileDigest(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkAllowlist checks that a hook is in the allowlist, with the given
+// digest of the hook. A nil allowlist permits all hooks.
+func checkAllowlist(allowlist map[string]string, h hook, digest string) error {
+	if allowlist == nil {
+		return nil
+	}
+	expected, ok := allowlist[h.name]
+	if !ok {
+		return fmt.Errorf("refused, not in the hook allowlist")
+	}
+	if !strings.EqualFold(digest, expected) {
+		return fmt.Errorf("refused, SHA-256 digest %s does not match the hook allowlist", digest)
+	}
+	return nil
+}
+
+// HookAllowlist returns an allowlist that permits the current versions of
+// all hooks in the hook directories of config
+func HookAllowlist(config *NFDConfig) (map[string]string, error) {
+	hooks, err := listHooks(config)
+	if err != nil {
+		return nil, err
+	}
+	allowlist := map[string]string{}
+	for _, h := range hooks {
+		digest, err := hookDigest(h.path)
+		if err != nil {
+			return nil, err
+		}
+		allowlist[h.name] = digest
+	}
+	return allowlist, nil
+}
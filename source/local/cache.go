@@ -0,0 +1,156 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// File name extension of hook metadata files, i.e. <hook name>.meta
+const metaExt = ".meta"
+
+// hookMeta is the contents of the metadata file of a hook
+type hookMeta struct {
+	// Time to reuse the output of a successful run of the hook
+	CacheTTL source.Duration `json:"cacheTTL,omitempty"`
+}
+
+// readHookMeta reads the metadata file of the hook at path, if one exists
+func readHookMeta(path string) (hookMeta, error) {
+	meta := hookMeta{}
+	data, err := ioutil.ReadFile(path + metaExt)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, err
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("invalid metadata file %s: %v", path+metaExt, err)
+	}
+	if meta.CacheTTL.Duration < 0 {
+		return meta, fmt.Errorf("invalid metadata file %s: negative cacheTTL", path+metaExt)
+	}
+	return meta, nil
+}
+
+// hookCacheEntry is the cached output of a hook
+type hookCacheEntry struct {
+	Expires time.Time `json:"expires"`
+	// SHA-256 digests of the hook executable and of the input of the hook
+	// at the time of running it. The entry is invalid if either has changed
+	// since.
+	HookDigest  string `json:"hookDigest"`
+	InputDigest string `json:"inputDigest"`
+	Stdout      []byte `json:"stdout"`
+}
+
+// hookInputDigest returns the hex encoded SHA-256 digest of the input of a hook,
+// i.e. its config and the features passed on stdin
+func hookInputDigest(hookConfig interface{}, stdin []byte) (string, error) {
+	data, err := json.Marshal(hookConfig)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(data)
+	// Separates the config from stdin, as it never occurs in JSON
+	h.Write([]byte{0})
+	h.Write(stdin)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func cacheFile(cacheDir string, hook string) string {
+	return filepath.Join(cacheDir, hook+".json")
+}
+
+// loadCachedOutput returns the cached output of a hook, if there is an
+// unexpired entry for the current digests of the hook and its input
+func loadCachedOutput(cacheDir string, hook string, hookDigest string, inputDigest string) (*hookCacheEntry, bool) {
+	data, err := ioutil.ReadFile(cacheFile(cacheDir, hook))
+	if err != nil {
+		return nil, false
+	}
+	entry := &hookCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false
+	}
+	if !time.Now().Before(entry.Expires) {
+		source.Explainf("hook %s: cached output expired at %v", hook, entry.Expires.Format(time.RFC3339))
+		return nil, false
+	}
+	if entry.HookDigest != hookDigest {
+		source.Explainf("hook %s: cached output discarded, hook has changed", hook)
+		return nil, false
+	}
+	if entry.InputDigest != inputDigest {
+		source.Explainf("hook %s: cached output discarded, input of the hook has changed", hook)
+		return nil, false
+	}
+	return entry, true
+}
+
+// storeCachedOutput stores the output of a hook in the cache for ttl, with
+// the digests of the hook and its input
+func storeCachedOutput(cacheDir string, hook string, hookDigest string, inputDigest string, stdout []byte, ttl time.Duration) error {
+	data, err := json.Marshal(hookCacheEntry{
+		Expires:     time.Now().Add(ttl),
+		HookDigest:  hookDigest,
+		InputDigest: inputDigest,
+		Stdout:      stdout,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	// Write atomically in order not to leave a partial entry behind
+	tmp, err := ioutil.TempFile(cacheDir, "."+hook+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cacheFile(cacheDir, hook))
+}
+
+// removeCachedOutput removes the cache entry of a hook, if any
+func removeCachedOutput(cacheDir string, hook string) error {
+	err := os.Remove(cacheFile(cacheDir, hook))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Exit codes of hooks with a special meaning. Any other non-zero exit code
+// means that the hook failed.
+const (
+	// The hook is not applicable to the node, e.g. the hardware it detects
+	// features of is not present. The hook yields no features.
+	exitNotApplicable = 100
+	// The hook detected some of its features only. Its output is used but
+	// marked as partial.
+	exitPartial = 101
+)
+
+// Values of the status feature of hooks that did not succeed fully
+const (
+	statusFailed  = "failed"
+	statusPartial = "partial"
+)
+
+// hookResult is the outcome of running one hook
+type hookResult struct {
+	output *output
+	err    error
+}
+
+// hook is one hook found in the hook directories
+type hook struct {
+	// Name of the hook, i.e. the hook directory prefix and the path of the
+	// hook relative to the directory, joined by '-'
+	name string
+	path string
+	// The hook prints its output in the JSON format, i.e. the hook file
+	// has the .json extension, which is not part of the hook name
+	jsonFormat bool
+}
+
+// hookDirs returns the configured hook directories, in order
+func hookDirs(config *NFDConfig) []HookDirConfig {
+	dirs := []HookDirConfig{}
+	if config.HookDir != "" {
+		dirs = append(dirs, HookDirConfig{Path: config.HookDir})
+	}
+	return append(dirs, config.HookDirs...)
+}
+
+// listHooks returns the hooks in all configured hook directories, in order.
+// Of hooks with the same name, e.g. "a-b" and "a/b" in a recursive hook
+// directory, only the first one is returned. An error is returned only if
+// none of the directories could be accessed.
+func listHooks(config *NFDConfig) ([]hook, error) {
+	hooks := []hook{}
+	paths := map[string]string{}
+	errs := []string{}
+	dirs := hookDirs(config)
+	for _, dir := range dirs {
+		dirHooks, err := listHookDir(dir.Path, dir.Prefix, dir.Recursive, config.IgnorePatterns)
+		if err != nil {
+			if os.IsNotExist(err) {
+				glog.Errorf("Hook directory %v does not exist", dir.Path)
+				continue
+			}
+			errs = append(errs, fmt.Sprintf("Unable to access %v: %v", dir.Path, err))
+			continue
+		}
+		for _, h := range dirHooks {
+			if path, ok := paths[h.name]; ok {
+				glog.Errorf("Skipping %v, hook name '%v' is already used by %v", h.path, h.name, path)
+				source.Explainf("hook %s: skipped, name %s is already used by %s", h.path, h.name, path)
+				continue
+			}
+			paths[h.name] = h.path
+			hooks = append(hooks, h)
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(dirs) {
+		return hooks, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	for _, err := range errs {
+		glog.Error(err)
+	}
+	return hooks, nil
+}
+
+// listHookDir returns the hooks in dir, in lexical order, the names of the
+// hooks being prefixed with prefix. Files matching any of the ignore patterns
+// and files that are not executable are skipped.
+func listHookDir(dir string, prefix string, recursive bool, ignorePatterns []string) ([]hook, error) {
+	hooks := []hook{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return hooks, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(dir, name)
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+		if pattern, ignored := matchIgnorePatterns(file.Name(), ignorePatterns); ignored {
+			source.Explainf("hook %s: skipped, matches ignore pattern %q", path, pattern)
+			continue
+		}
+		if file.IsDir() {
+			if !recursive {
+				source.Explainf("hook %s: skipped, directory", path)
+				continue
+			}
+			subHooks, err := listHookDir(path, name, recursive, ignorePatterns)
+			if err != nil {
+				glog.Errorf("Unable to access %v: %v", path, err)
+				continue
+			}
+			hooks = append(hooks, subHooks...)
+			continue
+		}
+		if strings.HasSuffix(file.Name(), metaExt) {
+			source.Explainf("hook %s: skipped, metadata file", path)
+			continue
+		}
+
+		// Follow symlinks
+		stat, err := os.Stat(path)
+		if err != nil {
+			glog.Errorf("Skipping %v, failed to get stat: %v", path, err)
+			continue
+		}
+		if !stat.Mode().IsRegular() {
+			source.Explainf("hook %s: skipped, not a regular file", path)
+			continue
+		}
+		if stat.Mode().Perm()&0111 == 0 {
+			source.Explainf("hook %s: skipped, not executable", path)
+			continue
+		}
+		jsonFormat := strings.HasSuffix(name, jsonExt)
+		hooks = append(hooks, hook{name: strings.TrimSuffix(name, jsonExt), path: path, jsonFormat: jsonFormat})
+	}
+	return hooks, nil
+}
+
+// matchIgnorePatterns returns the first of the patterns name matches, if any
+func matchIgnorePatterns(name string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Run all hooks in the hook directories, at most HookParallelism of them at a
+// time. The features discovered by other sources are passed to the hooks if
+// enabled in config.
+func discoverHooks(config *NFDConfig, discovered map[string]source.Features) (*output, error) {
+	out := newOutput()
+
+	hooks, err := listHooks(config)
+	if err != nil {
+		return out, err
+	}
+
+	var stdin []byte
+	if config.PassFeatures {
+		if discovered == nil {
+			discovered = map[string]source.Features{}
+		}
+		stdin, err = json.Marshal(discovered)
+		if err != nil {
+			return out, fmt.Errorf("Failed to encode features for hooks: %v", err)
+		}
+	}
+
+	var totalDeadline time.Time
+	if config.TotalHookTimeout.Duration > 0 {
+		totalDeadline = time.Now().Add(config.TotalHookTimeout.Duration)
+	}
+
+	results := make([]hookResult, len(hooks))
+	sem := make(chan struct{}, config.HookParallelism)
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, h hook) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			deadline := totalDeadline
+			if config.HookTimeout.Duration > 0 {
+				d := time.Now().Add(config.HookTimeout.Duration)
+				if deadline.IsZero() || d.Before(deadline) {
+					deadline = d
+				}
+			}
+			env, err := hookEnv(h.name, config.HookConfig[h.name])
+			if err != nil {
+				results[i] = hookResult{err: err}
+				return
+			}
+			hookOutput, err := runHook(h, config, env, stdin, deadline)
+			results[i] = hookResult{output: hookOutput, err: err}
+		}(i, h)
+	}
+	wg.Wait()
+
+	// Merge in hook order to keep the outcome independent of scheduling,
+	// i.e. in case of conflicting names the last hook wins. Hooks that did
+	// not fully succeed get a "<hook>.status" feature.
+	for i, h := range hooks {
+		if results[i].err != nil {
+			glog.Errorf("Source hook '%v' failed: %v", h.name, results[i].err)
+			out.features[h.name+".status"] = source.StringValue(statusFailed)
+			continue
+		}
+		out.merge(h.name, results[i].output)
+		if results[i].output.partial {
+			out.features[h.name+".status"] = source.StringValue(statusPartial)
+		}
+	}
+
+	return out, nil
+}
+
+// hookEnv returns the environment of a hook, i.e. the environment of NFD
+// extended with information about the node and the hook
+func hookEnv(hook string, hookConfig interface{}) ([]string, error) {
+	env := append(os.Environ(),
+		"NFD_VERSION="+source.NFDVersion,
+		"NFD_NODE_NAME="+source.NodeName,
+		"NFD_HOST_BOOT="+source.HostBootDir,
+		"NFD_HOST_ETC="+source.HostEtcDir,
+		"NFD_HOST_SYS="+source.HostSysDir,
+		"NFD_HOST_PROC="+source.HostProcDir,
+		"NFD_HOOK_NAME="+hook)
+	if hookConfig != nil {
+		data, err := json.Marshal(hookConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hook config: %v", err)
+		}
+		env = append(env, "NFD_HOOK_CONFIG="+string(data))
+	}
+	return env, nil
+}
+
+// Run one hook, with the given environment and stdin, killing it if it is
+// still running at deadline. A zero deadline means no time limit. Hook output
+// is parsed as JSON if the hook file has the .json extension. The output of successful runs is
+// cached in the cache directory for the cache TTL of the hook, unless the
+// cache directory is empty.
+func runHook(h hook, config *NFDConfig, env []string, stdin []byte, deadline time.Time) (*output, error) {
+	file, path := h.name, h.path
+	cacheDir := config.CacheDir
+
+	// The hook is checked against the allowlist, and, executed through the
+	// same open file, so that replacing the hook after the check has no
+	// effect
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The digest identifies the hook in the allowlist and in the cache
+	var digest string
+	if config.HookAllowlist != nil || cacheDir != "" {
+		if digest, err = fileDigest(f); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkAllowlist(config.HookAllowlist, h, digest); err != nil {
+		source.Explainf("hook %s: %v", file, err)
+		return nil, err
+	}
+	var execFile *os.File
+	if config.HookAllowlist != nil {
+		execFile = f
+	}
+
+	meta, err := readHookMeta(path)
+	if err != nil {
+		return nil, err
+	}
+	var input string
+	if cacheDir != "" {
+		if input, err = hookInputDigest(config.HookConfig[h.name], stdin); err != nil {
+			return nil, err
+		}
+		if entry, ok := loadCachedOutput(cacheDir, file, digest, input); ok {
+			source.Explainf("hook %s: using cached output until %v, stdout: %s", file, entry.Expires.Format(time.RFC3339), source.ExplainData(entry.Stdout))
+			return parseOutput(entry.Stdout, h.jsonFormat)
+		}
+	}
+
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		source.Explainf("hook %s: skipped, total hook timeout exceeded", file)
+		return nil, fmt.Errorf("not run, total hook timeout exceeded")
+	}
+
+	cmd, err := hookCommand(path, execFile, &config.HookSandbox)
+	if err != nil {
+		return nil, err
+	}
+	stdout := &limitedBuffer{limit: config.HookSandbox.MaxOutputSize}
+	stderr := &limitedBuffer{limit: config.HookSandbox.MaxOutputSize}
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	start := time.Now()
+	err = execHook(cmd, deadline)
+	duration := time.Since(start)
+
+	glog.Infof("Hook '%v' finished in %v, exit status: %v", file, duration, exitStatus(err))
+	source.Explainf("hook %s: duration: %v, exit status: %v, stdout: %s, stderr: %s", file, duration, exitStatus(err), source.ExplainData(stdout.Bytes()), source.ExplainData(stderr.Bytes()))
+
+	if stderr.Truncated() {
+		glog.Warningf("Hook '%v': stderr truncated to %d bytes", file, stderr.limit)
+	}
+	if err == nil && stdout.Truncated() {
+		err = fmt.Errorf("stdout exceeds the maximum size of %d bytes", stdout.limit)
+	}
+
+	// Forward stderr to our logger
+	lines := bytes.Split(stderr.Bytes(), []byte("\n"))
+	for i, line := range lines {
+		if i == len(lines)-1 && len(line) == 0 {
+			// Don't print the last empty string
+			break
+		}
+		glog.Errorf("%v: %s", file, line)
+	}
+
+	partial := false
+	if code, ok := exitCode(err); ok && code == exitNotApplicable {
+		source.Explainf("hook %s: not applicable", file)
+		return newOutput(), nil
+	} else if ok && code == exitPartial {
+		glog.Warningf("Hook '%v' reported partial results", file)
+		partial = true
+	} else if err != nil {
+		// Do not return any features if an error occurred
+		return nil, err
+	}
+
+	// Return features printed to stdout
+	stdoutBytes := stdout.Bytes()
+	out, err := parseOutput(stdoutBytes, h.jsonFormat)
+	if err != nil {
+		return nil, err
+	}
+	out.partial = partial
+
+	// Partial results are not cached, in order to retry on the next round
+	if cacheDir != "" && !partial {
+		ttl := meta.CacheTTL.Duration
+		if out.cacheTTL != nil {
+			ttl = *out.cacheTTL
+		}
+		if ttl > 0 {
+			err = storeCachedOutput(cacheDir, file, digest, input, stdoutBytes, ttl)
+		} else {
+			err = removeCachedOutput(cacheDir, file)
+		}
+		if err != nil {
+			glog.Errorf("Failed to update the cached output of hook '%v': %v", file, err)
+		}
+	}
+	return out, nil
+}
+
+// Time to wait for a killed hook to be reaped
+const killGracePeriod = time.Second
+
+// execHook runs a hook command in a process group of its own. If the hook has
+// not exited by deadline, the whole process group is killed so that no
+// children of the hook are left behind.
+func execHook(cmd *exec.Cmd, deadline time.Time) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-timeout:
+		// Wait() only returns after children holding stdout or stderr open
+		// have exited, too, so kill the whole group
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			glog.Errorf("Failed to kill process group of %v: %v", cmd.Path, err)
+		}
+		// Descendants that have left the process group, e.g. with
+		// setsid, survive, and, Wait() does not return for as long as
+		// they hold stdout or stderr open. Such hooks are abandoned, the
+		// output is still written to cmd.Stdout and cmd.Stderr, though.
+		grace := time.NewTimer(killGracePeriod)
+		defer grace.Stop()
+		select {
+		case <-done:
+		case <-grace.C:
+			glog.Errorf("Descendants of %v keep running after being killed, abandoning them", cmd.Path)
+		}
+		return fmt.Errorf("timed out, killed")
+	}
+}
+
+// exitCode returns the exit code of a hook from the error returned by
+// execHook(), if the hook exited by itself
+func exitCode(err error) (int, bool) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Exited() {
+		return 0, false
+	}
+	return status.ExitStatus(), true
+}
+
+// exitStatus returns a human-readable exit status of a hook
+func exitStatus(err error) string {
+	if err == nil {
+		return "0"
+	}
+	return err.Error()
+}
@@ -17,104 +17,263 @@ limitations under the License.
 package local
 
 import (
-	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
 )
 
-// Config
-var (
-	hookDir = "/etc/kubernetes/node-feature-discovery/source.d/"
-)
+// Configuration file options
+type NFDConfig struct {
+	// The default hook directory, hooks in which have no prefix
+	HookDir string `json:"hookDir,omitempty"`
+	// Additional hook directories
+	HookDirs []HookDirConfig `json:"hookDirs,omitempty"`
+	// Glob patterns of file names in hook directories that are not hooks
+	IgnorePatterns []string `json:"ignorePatterns,omitempty"`
+	FeaturesDir    string   `json:"featuresDir,omitempty"`
+	// Time limit for running one hook, zero means no limit
+	HookTimeout source.Duration `json:"hookTimeout,omitempty"`
+	// Time limit for running all hooks, zero means no limit
+	TotalHookTimeout source.Duration `json:"totalHookTimeout,omitempty"`
+	// Maximum number of hooks run in parallel
+	HookParallelism int `json:"hookParallelism,omitempty"`
+	// Config of each hook, passed to the hook in the NFD_HOOK_CONFIG
+	// environment variable, keyed by hook name
+	HookConfig map[string]interface{} `json:"hookConfig,omitempty"`
+	// Pass the features discovered by other sources to hooks as JSON on
+	// stdin
+	PassFeatures bool `json:"passFeatures,omitempty"`
+	// Directory for persisting cached hook output, empty disables caching
+	CacheDir string `json:"cacheDir,omitempty"`
+	// Restrictions applied to hooks
+	HookSandbox HookSandboxConfig `json:"hookSandbox,omitempty"`
+	// SHA-256 digests of the hooks that are permitted to run, keyed by hook
+	// name. All hooks are permitted if not set.
+	HookAllowlist map[string]string `json:"hookAllowlist,omitempty"`
+}
 
-// Implement FeatureSource interface
-type Source struct{}
+// Configuration of one hook directory
+type HookDirConfig struct {
+	Path string `json:"path"`
+	// Prefix of the names of the hooks in the directory
+	Prefix string `json:"prefix,omitempty"`
+	// Run hooks in subdirectories, too
+	Recursive bool `json:"recursive,omitempty"`
+}
 
-func (s Source) Name() string { return "local" }
+var hookPrefixRe = regexp.MustCompile(`^[A-Za-z0-9]([-.\w]*[A-Za-z0-9])?$`)
 
-func (s Source) Discover() (source.Features, error) {
-	features := source.Features{}
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *NFDConfig {
+	return &NFDConfig{
+		HookDir: "/etc/kubernetes/node-feature-discovery/source.d/",
+		// Editor backups and temporary files of package managers
+		IgnorePatterns:   []string{".*", "*~", "*.bak", "*.orig", "*.swp", "*.dpkg-*", "*.rpmnew", "*.rpmsave"},
+		FeaturesDir:      "/etc/kubernetes/node-feature-discovery/features.d/",
+		HookTimeout:      source.Duration{Duration: 10 * time.Second},
+		TotalHookTimeout: source.Duration{Duration: 30 * time.Second},
+		HookParallelism:  4,
+		CacheDir:         "/var/cache/node-feature-discovery/local/",
+		HookSandbox:      HookSandboxConfig{MaxOutputSize: 1024 * 1024},
+	}
+}
 
-	files, err := ioutil.ReadDir(hookDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			glog.Errorf("Hook directory %v does not exist", hookDir)
-			return features, nil
+// Validate checks the sanity of the config
+func (c *NFDConfig) Validate() error {
+	for _, dir := range c.HookDirs {
+		if dir.Path == "" {
+			return fmt.Errorf("path of hookDirs must not be empty")
+		}
+		if dir.Prefix != "" && !hookPrefixRe.MatchString(dir.Prefix) {
+			return fmt.Errorf("invalid prefix %q of hook directory %s", dir.Prefix, dir.Path)
 		}
-		return features, fmt.Errorf("Unable to access %v: %v", hookDir, err)
 	}
+	for name, digest := range c.HookAllowlist {
+		if !sha256Re.MatchString(digest) {
+			return fmt.Errorf("invalid SHA-256 digest %q of hook %s in hookAllowlist", digest, name)
+		}
+	}
+	if err := c.HookSandbox.Validate(); err != nil {
+		return fmt.Errorf("invalid hookSandbox: %v", err)
+	}
+	for _, pattern := range c.IgnorePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %v", pattern, err)
+		}
+	}
+	if c.FeaturesDir == "" {
+		return fmt.Errorf("featuresDir must not be empty")
+	}
+	if c.HookTimeout.Duration < 0 {
+		return fmt.Errorf("negative hookTimeout %v", c.HookTimeout)
+	}
+	if c.TotalHookTimeout.Duration < 0 {
+		return fmt.Errorf("negative totalHookTimeout %v", c.TotalHookTimeout)
+	}
+	if c.HookParallelism < 1 {
+		return fmt.Errorf("hookParallelism must be at least 1, got %d", c.HookParallelism)
+	}
+	return nil
+}
 
-	for _, file := range files {
-		hook := file.Name()
-		hookFeatures, err := runHook(hook)
-		if err != nil {
-			glog.Errorf("Source hook '%v' failed: %v", hook, err)
+// Implement FeatureSource, ConfigurableSource, NodeUpdater and
+// FeatureConsumer interfaces
+type Source struct {
+	config *NFDConfig
+	// Features discovered by other sources in the current discovery round
+	discovered map[string]source.Features
+	// Features with a time to live, retained until they expire
+	retained map[string]retainedFeature
+	updates  source.NodeUpdates
+}
+
+// retainedFeature is a feature that is published until it expires, even if
+// not discovered anymore
+type retainedFeature struct {
+	value   source.FeatureValue
+	expires time.Time
+}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         &Source{config: newDefaultConfig()},
+		Description:    "User-specific features from hooks",
+		DefaultEnabled: true,
+	})
+}
+
+func (s *Source) Name() string { return "local" }
+
+// NewConfig method of the ConfigurableSource interface
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
+
+// GetConfig method of the ConfigurableSource interface
+func (s *Source) GetConfig() source.Config { return s.config }
+
+// SetConfig method of the ConfigurableSource interface
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *NFDConfig:
+		s.config = v
+	default:
+		panic(fmt.Sprintf("invalid config type: %T", conf))
+	}
+}
+
+// SetDiscoveredFeatures method of the FeatureConsumer interface
+func (s *Source) SetDiscoveredFeatures(features map[string]source.Features) {
+	s.discovered = features
+}
+
+// NodeUpdates method of the NodeUpdater interface
+func (s *Source) NodeUpdates() source.NodeUpdates { return s.updates }
+
+func (s *Source) Discover() (source.Features, error) {
+	out := newOutput()
+
+	hookOutput, hookErr := discoverHooks(s.config, s.discovered)
+	if hookErr != nil {
+		glog.Error(hookErr)
+	}
+	out.merge("", hookOutput)
+
+	fileOutput, fileErr := discoverFeatureFiles(s.config.FeaturesDir)
+	if fileErr != nil {
+		glog.Error(fileErr)
+	}
+	out.merge("", fileOutput)
+
+	// Fail only if neither of the directories could be accessed
+	if hookErr != nil && fileErr != nil {
+		return nil, fmt.Errorf("%v, %v", hookErr, fileErr)
+	}
+
+	s.updates = source.NodeUpdates{
+		Annotations:       out.annotations,
+		ExtendedResources: out.resources,
+	}
+	return s.retain(out), nil
+}
+
+// retain updates the set of retained features from out, and, returns the
+// features of out together with the retained features that have not expired
+func (s *Source) retain(out *output) source.Features {
+	now := time.Now()
+	if s.retained == nil {
+		s.retained = map[string]retainedFeature{}
+	}
+
+	for name, value := range out.features {
+		if ttl, ok := out.ttls[name]; ok {
+			s.retained[name] = retainedFeature{value: value, expires: now.Add(ttl)}
+		} else {
+			delete(s.retained, name)
+		}
+	}
+
+	features := out.features
+	for name, f := range s.retained {
+		if !now.Before(f.expires) {
+			source.Explainf("feature %s: expired", name)
+			delete(s.retained, name)
 			continue
 		}
-		for feature, value := range hookFeatures {
-			features[hook+"-"+feature] = value
+		if _, ok := features[name]; !ok {
+			source.Explainf("feature %s: not discovered, retained until %v", name, f.expires.Format(time.RFC3339))
+			features[name] = f.value
 		}
 	}
-
-	return features, nil
+	return features
 }
 
-// Run one hook
-func runHook(file string) (map[string]string, error) {
-	features := map[string]string{}
+// Read all feature files in featuresDir. Files with the .json extension are
+// in the JSON format, the extension not being a part of the feature names.
+func discoverFeatureFiles(featuresDir string) (*output, error) {
+	out := newOutput()
 
-	path := filepath.Join(hookDir, file)
-	filestat, err := os.Stat(path)
+	files, err := ioutil.ReadDir(featuresDir)
 	if err != nil {
-		glog.Errorf("Skipping %v, failed to get stat: %v", path, err)
-		return features, err
-	}
-
-	if filestat.Mode().IsRegular() {
-		cmd := exec.Command(path)
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		// Run hook
-		err = cmd.Run()
-
-		// Forward stderr to our logger
-		lines := bytes.Split(stderr.Bytes(), []byte("\n"))
-		for i, line := range lines {
-			if i == len(lines)-1 && len(line) == 0 {
-				// Don't print the last empty string
-				break
-			}
-			glog.Errorf("%v: %s", file, line)
+		if os.IsNotExist(err) {
+			glog.Infof("Features directory %v does not exist", featuresDir)
+			return out, nil
 		}
+		return out, fmt.Errorf("Unable to access %v: %v", featuresDir, err)
+	}
 
-		// Do not return any features if an error occurred
+	for _, file := range files {
+		name := file.Name()
+		// Skip hidden files, e.g. temporary files of atomic writes
+		if strings.HasPrefix(name, ".") {
+			source.Explainf("feature file %s: skipped, hidden file", name)
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			source.Explainf("feature file %s: skipped, not a regular file", name)
+			continue
+		}
+		data, err := source.ReadFile(filepath.Join(featuresDir, name))
 		if err != nil {
-			return features, err
+			glog.Errorf("Failed to read feature file '%v': %v", name, err)
+			continue
 		}
-
-		// Return features printed to stdout
-		lines = bytes.Split(stdout.Bytes(), []byte("\n"))
-		for _, line := range lines {
-			if len(line) > 0 {
-				lineSplit := strings.SplitN(string(line), "=", 2)
-				if len(lineSplit) == 1 {
-					features[lineSplit[0]] = "true"
-				} else {
-					features[lineSplit[0]] = lineSplit[1]
-				}
-			}
+		jsonFormat := strings.HasSuffix(name, jsonExt)
+		fileOutput, err := parseOutput(data, jsonFormat)
+		if err != nil {
+			glog.Errorf("Failed to parse feature file '%v': %v", name, err)
+			continue
+		}
+		if jsonFormat {
+			name = strings.TrimSuffix(name, jsonExt)
 		}
+		out.merge(name, fileOutput)
 	}
 
-	return features, nil
+	return out, nil
 }
@@ -0,0 +1,358 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newTestSource returns a source using temporary hook and feature
+// directories
+func newTestSource() (*Source, func()) {
+	dir, err := ioutil.TempDir("", "nfd-test-local-")
+	So(err, ShouldBeNil)
+
+	config := newDefaultConfig()
+	config.HookDir = filepath.Join(dir, "source.d")
+	config.FeaturesDir = filepath.Join(dir, "features.d")
+	config.CacheDir = filepath.Join(dir, "cache")
+	So(os.Mkdir(config.HookDir, 0755), ShouldBeNil)
+	So(os.Mkdir(config.FeaturesDir, 0755), ShouldBeNil)
+
+	s := &Source{}
+	s.SetConfig(config)
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func writeFile(dir, name, content string, mode os.FileMode) {
+	So(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), mode), ShouldBeNil)
+}
+
+func TestDiscover(t *testing.T) {
+	Convey("When discovering local features", t, func() {
+		s, cleanup := newTestSource()
+		defer cleanup()
+
+		Convey("Hook output is parsed into features", func() {
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FOO\necho BAR=baz\necho ignored >&2\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-FOO": source.BoolValue(true),
+				"hook-BAR": source.StringValue("baz"),
+			})
+		})
+
+		Convey("Failing hooks produce only a status feature", func() {
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FOO\nexit 1\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"hook.status": source.StringValue("failed")})
+		})
+
+		Convey("Hooks may exit as not applicable or with partial results", func() {
+			writeFile(s.config.HookDir, "na", "#!/bin/sh\necho FOO\nexit 100\n", 0755)
+			writeFile(s.config.HookDir, "partial", "#!/bin/sh\necho FOO\nexit 101\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"partial-FOO":    source.BoolValue(true),
+				"partial.status": source.StringValue("partial"),
+			})
+		})
+
+		Convey("Hooks exceeding their timeout are killed with their children", func() {
+			s.config.HookTimeout = source.Duration{Duration: 200 * time.Millisecond}
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FOO\nsleep 10 &\nsleep 10\n", 0755)
+
+			start := time.Now()
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"hook.status": source.StringValue("failed")})
+			So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+		})
+
+		Convey("Killed hooks are abandoned if descendants hold their output open", func() {
+			if _, err := exec.LookPath("setsid"); err != nil {
+				return
+			}
+			s.config.HookTimeout = source.Duration{Duration: 200 * time.Millisecond}
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\nsetsid sleep 5 &\nsleep 10\n", 0755)
+
+			start := time.Now()
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"hook.status": source.StringValue("failed")})
+			So(time.Since(start), ShouldBeLessThan, 4*time.Second)
+		})
+
+		Convey("Hooks are not started after the total timeout", func() {
+			s.config.HookParallelism = 1
+			s.config.TotalHookTimeout = source.Duration{Duration: 200 * time.Millisecond}
+			writeFile(s.config.HookDir, "a", "#!/bin/sh\nsleep 1\necho FOO\n", 0755)
+			writeFile(s.config.HookDir, "b", "#!/bin/sh\necho FOO\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"a.status": source.StringValue("failed"),
+				"b.status": source.StringValue("failed"),
+			})
+		})
+
+		Convey("Hooks get their config and the features of other sources", func() {
+			s.config.HookConfig = map[string]interface{}{"hook": map[string]interface{}{"threshold": 3}}
+			s.config.PassFeatures = true
+			s.SetDiscoveredFeatures(map[string]source.Features{"kernel": {"version.major": source.IntValue(4)}})
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho NAME=$NFD_HOOK_NAME\necho CONFIG=$NFD_HOOK_CONFIG\necho SYS=$NFD_HOST_SYS\necho STDIN=$(cat)\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-NAME":   source.StringValue("hook"),
+				"hook-CONFIG": source.StringValue(`{"threshold":3}`),
+				"hook-SYS":    source.StringValue(source.HostSysDir),
+				"hook-STDIN":  source.StringValue(`{"kernel":{"version.major":4}}`),
+			})
+		})
+
+		Convey("Hook output is cached for the cache TTL of the hook", func() {
+			counter := filepath.Join(s.config.HookDir, "..", "runs")
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho x >> "+counter+"\necho RUNS=$(wc -l < "+counter+")\n", 0755)
+			writeFile(s.config.HookDir, "hook.meta", "cacheTTL: 1h\n", 0644)
+
+			expected := source.Features{"hook-RUNS": source.StringValue("1")}
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, expected)
+
+			// The cache persists over restarts
+			restarted := &Source{}
+			restarted.SetConfig(s.config)
+			features, err = restarted.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, expected)
+
+			// A hook of the same size and modification time is a change
+			path := filepath.Join(s.config.HookDir, "hook")
+			stat, err := os.Stat(path)
+			So(err, ShouldBeNil)
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho x >> "+counter+"\necho RUNZ=$(wc -l < "+counter+")\n", 0755)
+			So(os.Chtimes(path, stat.ModTime(), stat.ModTime()), ShouldBeNil)
+			features, err = s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"hook-RUNZ": source.StringValue("2")})
+
+			// So is a change in the config of the hook
+			s.config.HookConfig = map[string]interface{}{"hook": "new"}
+			features, err = s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"hook-RUNZ": source.StringValue("3")})
+		})
+
+		Convey("Hooks are run from all hook directories", func() {
+			extra := filepath.Join(s.config.HookDir, "..", "extra.d")
+			So(os.MkdirAll(filepath.Join(extra, "sub"), 0755), ShouldBeNil)
+			s.config.HookDirs = []HookDirConfig{{Path: extra, Prefix: "extra", Recursive: true}}
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FOO\n", 0755)
+			writeFile(s.config.HookDir, "hook~", "#!/bin/sh\necho BACKUP\n", 0755)
+			writeFile(s.config.HookDir, "README", "Not a hook\n", 0644)
+			writeFile(extra, "hook", "#!/bin/sh\necho BAR\n", 0755)
+			writeFile(filepath.Join(extra, "sub"), "hook", "#!/bin/sh\necho BAZ\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-FOO":           source.BoolValue(true),
+				"extra-hook-BAR":     source.BoolValue(true),
+				"extra-sub-hook-BAZ": source.BoolValue(true),
+			})
+		})
+
+		Convey("Only the first of hooks with the same name is run", func() {
+			s.config.HookDirs = []HookDirConfig{{Path: s.config.HookDir, Recursive: true}}
+			s.config.HookDir = ""
+			So(os.MkdirAll(filepath.Join(s.config.HookDirs[0].Path, "a"), 0755), ShouldBeNil)
+			writeFile(s.config.HookDirs[0].Path, "a-b", "#!/bin/sh\necho FOO\n", 0755)
+			writeFile(filepath.Join(s.config.HookDirs[0].Path, "a"), "b", "#!/bin/sh\necho BAR\n", 0755)
+			writeFile(s.config.HookDirs[0].Path, "c", "#!/bin/sh\necho BAZ\n", 0755)
+			writeFile(s.config.HookDirs[0].Path, "c.json", `#!/bin/sh
+echo '{"features": [{"name": "QUX"}]}'
+`, 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"a-b-BAR": source.BoolValue(true),
+				"c-BAZ":   source.BoolValue(true),
+			})
+		})
+
+		Convey("Sandboxed hooks run with resource limits and without capabilities", func() {
+			s.config.HookSandbox = HookSandboxConfig{
+				CPUTime:          source.Duration{Duration: 1500 * time.Millisecond},
+				MemoryLimit:      1024 * 1024 * 1024,
+				MaxOutputSize:    64,
+				DropCapabilities: true,
+			}
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho CPU=$(ulimit -t)\necho MEM=$(ulimit -v)\necho CAPS=$(awk '/CapEff/ {print $2}' /proc/self/status)\n", 0755)
+			writeFile(s.config.HookDir, "verbose", "#!/bin/sh\nhead -c 100 /dev/zero | tr '\\0' x\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-CPU":  source.StringValue("2"),
+				"hook-MEM":  source.StringValue("1048576"),
+				"hook-CAPS": source.StringValue("0000000000000000"),
+				// Exceeded the maximum output size
+				"verbose.status": source.StringValue("failed"),
+			})
+		})
+
+		Convey("Only hooks in the allowlist are run", func() {
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FOO\n", 0755)
+			writeFile(s.config.HookDir, "modified", "#!/bin/sh\necho BAR\n", 0755)
+			allowlist, err := HookAllowlist(s.config)
+			So(err, ShouldBeNil)
+			So(allowlist, ShouldHaveLength, 2)
+
+			s.config.HookAllowlist = allowlist
+			writeFile(s.config.HookDir, "modified", "#!/bin/sh\necho BAZ\n", 0755)
+			writeFile(s.config.HookDir, "unknown", "#!/bin/sh\necho QUX\n", 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-FOO":        source.BoolValue(true),
+				"modified.status": source.StringValue("failed"),
+				"unknown.status":  source.StringValue("failed"),
+			})
+		})
+
+		Convey("Allowlisted hooks are executed through the checked file", func() {
+			writeFile(s.config.HookDir, "hook", "#!/bin/sh\necho FD=$(basename $0)\n", 0755)
+			allowlist, err := HookAllowlist(s.config)
+			So(err, ShouldBeNil)
+			s.config.HookAllowlist = allowlist
+
+			expected := source.Features{"hook-FD": source.StringValue("3")}
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, expected)
+
+			// Through the sandbox, too
+			s.config.HookSandbox = HookSandboxConfig{DropCapabilities: true}
+			features, err = s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, expected)
+		})
+
+		Convey("Feature files are read without execution", func() {
+			writeFile(s.config.FeaturesDir, "static", "# comment\nFOO\n\nBAR=baz\n", 0644)
+			writeFile(s.config.FeaturesDir, ".static.tmp", "IGNORED\n", 0644)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"static-FOO": source.BoolValue(true),
+				"static-BAR": source.StringValue("baz"),
+			})
+		})
+
+		Convey("JSON hook output is parsed into typed features and node updates", func() {
+			writeFile(s.config.HookDir, "hook.json", `#!/bin/sh
+cat << EOF
+{
+  "features": [
+    {"name": "FOO"},
+    {"name": "NUM", "value": 42},
+    {"name": "LIST", "value": ["a", "b"]}
+  ],
+  "annotations": {"NOTE": "line1\\nline2"},
+  "extendedResources": {"widgets": 4}
+}
+EOF
+`, 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"hook-FOO":  source.BoolValue(true),
+				"hook-NUM":  source.IntValue(42),
+				"hook-LIST": source.ListValue{source.StringValue("a"), source.StringValue("b")},
+			})
+			So(s.NodeUpdates(), ShouldResemble, source.NodeUpdates{
+				Annotations:       map[string]string{"hook-NOTE": "line1\nline2"},
+				ExtendedResources: map[string]int64{"hook-widgets": 4},
+			})
+		})
+
+		Convey("Only the output of hooks with the .json extension is parsed as JSON", func() {
+			writeFile(s.config.HookDir, "lines", "#!/bin/sh\necho '{FOO}'\n", 0755)
+			writeFile(s.config.HookDir, "null.json", `#!/bin/sh
+echo '{"features": [{"name": "FOO", "value": null}]}'
+`, 0755)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"lines-{FOO}": source.BoolValue(true),
+				"null.status": source.StringValue(statusFailed),
+			})
+		})
+
+		Convey("Features with a TTL are retained until they expire", func() {
+			writeFile(s.config.FeaturesDir, "static.json", `{"features": [{"name": "FOO", "ttl": "1h"}, {"name": "BAR"}]}`, 0644)
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{
+				"static-FOO": source.BoolValue(true),
+				"static-BAR": source.BoolValue(true),
+			})
+
+			So(os.Remove(filepath.Join(s.config.FeaturesDir, "static.json")), ShouldBeNil)
+			features, err = s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, source.Features{"static-FOO": source.BoolValue(true)})
+
+			s.retained["static-FOO"] = retainedFeature{value: source.BoolValue(true), expires: time.Now()}
+			features, err = s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldBeEmpty)
+		})
+
+		Convey("Missing directories are not an error", func() {
+			So(os.RemoveAll(s.config.HookDir), ShouldBeNil)
+			So(os.RemoveAll(s.config.FeaturesDir), ShouldBeNil)
+
+			features, err := s.Discover()
+			So(err, ShouldBeNil)
+			So(features, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// File name extension of feature files and hooks in the JSON format
+const jsonExt = ".json"
+
+// output is the parsed output of a hook or the contents of a feature file
+type output struct {
+	features source.Features
+	// Time to live of features, for features that have one
+	ttls        map[string]time.Duration
+	annotations map[string]string
+	resources   map[string]int64
+	// Time to cache the output of a hook, if specified in the output
+	cacheTTL *time.Duration
+	// The hook reported its output to be incomplete
+	partial bool
+}
+
+func newOutput() *output {
+	return &output{
+		features:    source.Features{},
+		ttls:        map[string]time.Duration{},
+		annotations: map[string]string{},
+		resources:   map[string]int64{},
+	}
+}
+
+// merge adds everything in o2 into o, prefixing all names with
+// "<prefix>-" unless prefix is empty
+func (o *output) merge(prefix string, o2 *output) {
+	if prefix != "" {
+		prefix += "-"
+	}
+	for name, value := range o2.features {
+		o.features[prefix+name] = value
+	}
+	for name, ttl := range o2.ttls {
+		o.ttls[prefix+name] = ttl
+	}
+	for name, value := range o2.annotations {
+		o.annotations[prefix+name] = value
+	}
+	for name, value := range o2.resources {
+		o.resources[prefix+name] = value
+	}
+}
+
+// jsonOutput is the JSON format of hook output and feature files
+type jsonOutput struct {
+	Features          []jsonFeature     `json:"features,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	ExtendedResources map[string]int64  `json:"extendedResources,omitempty"`
+	CacheTTL          *source.Duration  `json:"cacheTTL,omitempty"`
+}
+
+type jsonFeature struct {
+	Name string `json:"name"`
+	// Value of the feature, true if omitted. Null is not a valid value.
+	Value json.RawMessage `json:"value,omitempty"`
+	// Time to live, zero meaning that the feature is not retained
+	TTL source.Duration `json:"ttl,omitempty"`
+}
+
+// Parse hook output or a feature file, either in the JSON or in the line
+// based format
+func parseOutput(data []byte, jsonFormat bool) (*output, error) {
+	if jsonFormat {
+		return parseJSONOutput(data)
+	}
+	o := newOutput()
+	o.features = parseFeatures(data)
+	return o, nil
+}
+
+// Parse hook output or a feature file in the JSON format
+func parseJSONOutput(data []byte) (*output, error) {
+	raw := jsonOutput{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON output: %v", err)
+	}
+
+	o := newOutput()
+	for _, f := range raw.Features {
+		if f.Name == "" {
+			return nil, fmt.Errorf("invalid JSON output: feature without a name")
+		}
+		if f.TTL.Duration < 0 {
+			return nil, fmt.Errorf("invalid JSON output: negative ttl of feature %q", f.Name)
+		}
+		var value source.FeatureValue = source.BoolValue(true)
+		if len(f.Value) > 0 {
+			v, err := parseJSONValue(f.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON output: feature %q: %v", f.Name, err)
+			}
+			value = v
+		}
+		o.features[f.Name] = value
+		if f.TTL.Duration > 0 {
+			o.ttls[f.Name] = f.TTL.Duration
+		}
+	}
+	for name, value := range raw.Annotations {
+		o.annotations[name] = value
+	}
+	for name, value := range raw.ExtendedResources {
+		o.resources[name] = value
+	}
+	if raw.CacheTTL != nil {
+		if raw.CacheTTL.Duration < 0 {
+			return nil, fmt.Errorf("invalid JSON output: negative cacheTTL")
+		}
+		o.cacheTTL = &raw.CacheTTL.Duration
+	}
+	return o, nil
+}
+
+// Parse the value of a feature in JSON output
+func parseJSONValue(data []byte) (source.FeatureValue, error) {
+	var raw interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("null value")
+	}
+	return source.NewValue(raw)
+}
+
+// Parse features from hook output or a feature file. Each line contains one
+// feature, either "<name>" for binary features or "<name>=<value>". Empty
+// lines and lines starting with '#' are ignored.
+func parseFeatures(data []byte) source.Features {
+	features := source.Features{}
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines {
+		if len(line) > 0 && line[0] != '#' {
+			lineSplit := strings.SplitN(string(line), "=", 2)
+			if len(lineSplit) == 1 {
+				features[lineSplit[0]] = source.BoolValue(true)
+			} else {
+				features[lineSplit[0]] = source.StringValue(lineSplit[1])
+			}
+		}
+	}
+	return features
+}
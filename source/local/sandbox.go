@@ -0,0 +1,266 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+// Restrictions applied to hooks
+type HookSandboxConfig struct {
+	// User and group to run hooks as, by default those of NFD
+	UID *uint32 `json:"uid,omitempty"`
+	GID *uint32 `json:"gid,omitempty"`
+	// CPU time limit (RLIMIT_CPU), rounded up to full seconds
+	CPUTime source.Duration `json:"cpuTime,omitempty"`
+	// Address space limit (RLIMIT_AS) in bytes
+	MemoryLimit int64 `json:"memoryLimit,omitempty"`
+	// Maximum size of stdout and stderr output in bytes, each
+	MaxOutputSize int64 `json:"maxOutputSize,omitempty"`
+	// Drop all capabilities, and, disallow gaining privileges e.g. via
+	// setuid executables
+	DropCapabilities bool `json:"dropCapabilities,omitempty"`
+}
+
+// Validate checks the sanity of the sandbox config
+func (c *HookSandboxConfig) Validate() error {
+	if c.CPUTime.Duration < 0 {
+		return fmt.Errorf("negative cpuTime %v", c.CPUTime)
+	}
+	if c.MemoryLimit < 0 {
+		return fmt.Errorf("negative memoryLimit %d", c.MemoryLimit)
+	}
+	if c.MaxOutputSize < 0 {
+		return fmt.Errorf("negative maxOutputSize %d", c.MaxOutputSize)
+	}
+	return nil
+}
+
+// argv[0] of NFD re-executing itself in order to run a sandboxed hook
+const sandboxArgv0 = "nfd-hook-sandbox"
+
+// sandboxParams are the restrictions that NFD applies to itself before
+// executing a hook, passed in argv[1]
+type sandboxParams struct {
+	CPUTime          uint64 `json:"cpuTime,omitempty"`
+	MemoryLimit      uint64 `json:"memoryLimit,omitempty"`
+	DropCapabilities bool   `json:"dropCapabilities,omitempty"`
+}
+
+func init() {
+	if len(os.Args) == 4 && os.Args[0] == sandboxArgv0 {
+		execSandboxed(os.Args[1], os.Args[2], os.Args[3])
+	}
+}
+
+// File descriptor of the hook file passed to the hook command, if any
+const hookFd = 3
+
+// hookCommand returns the command for running the hook at path. If f is not
+// nil, the hook is executed through it, i.e. as /proc/self/fd/3, instead of
+// the path. Resource limits and capability dropping cannot be set up by the
+// Go runtime between fork and exec, so NFD is re-executed to apply them to
+// itself before executing the hook. This way no process of the hook ever runs
+// without the restrictions.
+func hookCommand(path string, f *os.File, sandbox *HookSandboxConfig) (*exec.Cmd, error) {
+	params := sandboxParams{
+		MemoryLimit:      uint64(sandbox.MemoryLimit),
+		DropCapabilities: sandbox.DropCapabilities,
+	}
+	if d := sandbox.CPUTime.Duration; d > 0 {
+		params.CPUTime = uint64((d + 999999999) / 1000000000)
+	}
+
+	execPath := path
+	if f != nil {
+		execPath = fmt.Sprintf("/proc/self/fd/%d", hookFd)
+	}
+
+	var cmd *exec.Cmd
+	if params == (sandboxParams{}) {
+		cmd = &exec.Cmd{
+			Path: execPath,
+			Args: []string{path},
+		}
+	} else {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		cmd = &exec.Cmd{
+			Path: "/proc/self/exe",
+			Args: []string{sandboxArgv0, string(data), execPath, path},
+		}
+	}
+	if f != nil {
+		// The first extra file is the first fd after stdin, stdout and
+		// stderr, i.e. hookFd
+		cmd.ExtraFiles = []*os.File{f}
+	}
+
+	if sandbox.UID != nil || sandbox.GID != nil {
+		cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid())}
+		if sandbox.UID != nil {
+			cred.Uid = *sandbox.UID
+		}
+		if sandbox.GID != nil {
+			cred.Gid = *sandbox.GID
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+	return cmd, nil
+}
+
+// execSandboxed applies the restrictions encoded in params to the current
+// process and executes the hook at execPath, with argv[0] set to path. It
+// does not return.
+func execSandboxed(params string, execPath string, path string) {
+	// Capabilities are per-thread, so the thread dropping them must execute
+	// the hook
+	runtime.LockOSThread()
+
+	err := func() error {
+		p := sandboxParams{}
+		if err := json.Unmarshal([]byte(params), &p); err != nil {
+			return fmt.Errorf("invalid sandbox parameters: %v", err)
+		}
+		if p.CPUTime > 0 {
+			if err := setrlimit(syscall.RLIMIT_CPU, p.CPUTime); err != nil {
+				return fmt.Errorf("failed to set CPU time limit: %v", err)
+			}
+		}
+		if p.MemoryLimit > 0 {
+			if err := setrlimit(syscall.RLIMIT_AS, p.MemoryLimit); err != nil {
+				return fmt.Errorf("failed to set memory limit: %v", err)
+			}
+		}
+		if p.DropCapabilities {
+			if err := dropCapabilities(); err != nil {
+				return fmt.Errorf("failed to drop capabilities: %v", err)
+			}
+		}
+		return syscall.Exec(execPath, []string{path}, os.Environ())
+	}()
+
+	fmt.Fprintf(os.Stderr, "%s: %s: %v\n", sandboxArgv0, path, err)
+	os.Exit(126)
+}
+
+func setrlimit(resource int, limit uint64) error {
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: limit, Max: limit})
+}
+
+// Constants of prctl(2) and capset(2)
+const (
+	prCapbsetDrop           = 24
+	prSetNoNewPrivs         = 38
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// dropCapabilities clears the capability bounding set and the capabilities
+// of the current thread, and, sets the no_new_privs flag
+func dropCapabilities() error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+
+	// Dropping from the bounding set requires CAP_SETPCAP, without which
+	// there are no capabilities to drop, and, no_new_privs prevents gaining
+	// them
+	for c := uintptr(0); ; c++ {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetDrop, c, 0)
+		if errno == syscall.EINVAL || errno == syscall.EPERM {
+			// Past the last capability known to the kernel, or, no
+			// CAP_SETPCAP
+			break
+		} else if errno != 0 {
+			return errno
+		}
+	}
+
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	data := [2]capData{}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// limitedBuffer is a buffer that keeps at most limit bytes, or everything if
+// limit is zero, silently discarding the rest. The bytes.Buffer is not
+// embedded, in order not to inherit its ReadFrom() which io.Copy() would
+// prefer over Write(). The buffer may be written to after the hook has been
+// abandoned, see execHook(), so access is synchronized.
+type limitedBuffer struct {
+	mutex     sync.Mutex
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+// Bytes returns a copy of the contents of the buffer
+func (b *limitedBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+// Truncated tells if data has been discarded
+func (b *limitedBuffer) Truncated() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.truncated
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	n := len(p)
+	if b.limit > 0 {
+		room := b.limit - int64(b.buf.Len())
+		if room < 0 {
+			room = 0
+		}
+		if int64(len(p)) > room {
+			p = p[:room]
+			b.truncated = true
+		}
+	}
+	b.buf.Write(p)
+	return n, nil
+}
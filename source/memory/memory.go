@@ -31,6 +31,14 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "NUMA and memory topology",
+		DefaultEnabled: true,
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "memory" }
 
@@ -40,7 +48,7 @@ func (s Source) Discover() (source.Features, error) {
 
 	// Find out how many nodes are online
 	// Multiple nodes is a sign of NUMA
-	bytes, err := ioutil.ReadFile("/sys/devices/system/node/online")
+	bytes, err := source.ReadFile("/sys/devices/system/node/online")
 	if err != nil {
 		glog.Errorf("can't read /sys/devices/system/node/online: %s", err.Error())
 	} else {
@@ -50,7 +58,7 @@ func (s Source) Discover() (source.Features, error) {
 		// presence of newline requires TrimSpace
 		if strings.TrimSpace(string(bytes)) != "0" {
 			// more than one node means NUMA
-			features["numa"] = true
+			features["numa"] = source.BoolValue(true)
 		}
 	}
 
@@ -69,8 +77,9 @@ func (s Source) Discover() (source.Features, error) {
 	}
 	if nodeCount > 0 && physicalCount > 0 {
 		glog.Errorf("Detected %v NUMA node(s) and %v Physical ID(s)", nodeCount, physicalCount)
+		source.Explainf("die_clustering: detected %v NUMA node(s) and %v physical id(s)", nodeCount, physicalCount)
 		if nodeCount > physicalCount {
-			features["die_clustering"] = true
+			features["die_clustering"] = source.BoolValue(true)
 		}
 	}
 
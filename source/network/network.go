@@ -20,7 +20,6 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/golang/glog"
-	"io/ioutil"
 	"net"
 	"strconv"
 	"strings"
@@ -31,6 +30,14 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "SR-IOV capable network interfaces",
+		DefaultEnabled: true,
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "network" }
 
@@ -45,7 +52,7 @@ func (s Source) Discover() (source.Features, error) {
 	for _, netInterface := range netInterfaces {
 		if strings.Contains(netInterface.Flags.String(), "up") && !strings.Contains(netInterface.Flags.String(), "loopback") {
 			totalVfsPath := "/sys/class/net/" + netInterface.Name + "/device/sriov_totalvfs"
-			totalBytes, err := ioutil.ReadFile(totalVfsPath)
+			totalBytes, err := source.ReadFile(totalVfsPath)
 			if err != nil {
 				glog.Errorf("SR-IOV not supported for network interface: %s: %v", netInterface.Name, err)
 				continue
@@ -59,9 +66,9 @@ func (s Source) Discover() (source.Features, error) {
 			if t > 0 {
 				glog.Infof("SR-IOV capability is detected on the network interface: %s", netInterface.Name)
 				glog.Infof("%d maximum supported number of virtual functions on network interface: %s", t, netInterface.Name)
-				features["sriov.capable"] = true
+				features["sriov.capable"] = source.BoolValue(true)
 				numVfsPath := "/sys/class/net/" + netInterface.Name + "/device/sriov_numvfs"
-				numBytes, err := ioutil.ReadFile(numVfsPath)
+				numBytes, err := source.ReadFile(numVfsPath)
 				if err != nil {
 					glog.Errorf("SR-IOV not configured for network interface: %s: %s", netInterface.Name, err)
 					continue
@@ -74,7 +81,7 @@ func (s Source) Discover() (source.Features, error) {
 				}
 				if n > 0 {
 					glog.Infof("%d virtual functions configured on network interface: %s", n, netInterface.Name)
-					features["sriov.configured"] = true
+					features["sriov.configured"] = source.BoolValue(true)
 					break
 				} else if n == 0 {
 					glog.Errorf("SR-IOV not configured on network interface: %s", netInterface.Name)
@@ -34,6 +34,14 @@ var osReleaseFields = [...]string{
 // Implement FeatureSource interface
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Operating system release",
+		DefaultEnabled: true,
+	})
+}
+
 func (s Source) Name() string { return "os" }
 
 func (s Source) Discover() (source.Features, error) {
@@ -41,11 +49,15 @@ func (s Source) Discover() (source.Features, error) {
 
 	release, err := parseOSRelease()
 	if err != nil {
+		source.Explainf("failed to read os-release: %v", err)
 		glog.Errorf("Failed to get os-release: %v", err)
 	} else {
 		for _, key := range osReleaseFields {
 			if value, exists := release[key]; exists {
-				features["release."+key] = value
+				source.Explainf("release.%s: found %s=%q in os-release", key, key, value)
+				features["release."+key] = source.StringValue(value)
+			} else {
+				source.Explainf("release.%s: %s not found in os-release", key, key)
 			}
 		}
 	}
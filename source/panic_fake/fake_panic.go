@@ -21,6 +21,13 @@ import "github.com/kubernetes-incubator/node-feature-discovery/source"
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:      Source{},
+		Description: "Source that panics, for testing only",
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "panic_fake" }
 
@@ -36,21 +36,50 @@ type NFDConfig struct {
 	DeviceLabelFields    []string `json:"deviceLabelFields,omitempty"`
 }
 
-var Config = NFDConfig{
-	DeviceClassWhitelist: []string{"03", "0b40", "12"},
-	DeviceLabelFields:    []string{"class", "vendor"},
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *NFDConfig {
+	return &NFDConfig{
+		DeviceClassWhitelist: []string{"03", "0b40", "12"},
+		DeviceLabelFields:    []string{"class", "vendor"},
+	}
 }
 
 var devLabelAttrs = []string{"class", "vendor", "device", "subsystem_vendor", "subsystem_device"}
 
-// Implement FeatureSource interface
-type Source struct{}
+// Implement FeatureSource and ConfigurableSource interfaces
+type Source struct {
+	config *NFDConfig
+}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         &Source{config: newDefaultConfig()},
+		Description:    "PCI devices",
+		DefaultEnabled: true,
+	})
+}
 
 // Return name of the feature source
-func (s Source) Name() string { return "pci" }
+func (s *Source) Name() string { return "pci" }
+
+// NewConfig method of the ConfigurableSource interface
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
+
+// GetConfig method of the ConfigurableSource interface
+func (s *Source) GetConfig() source.Config { return s.config }
+
+// SetConfig method of the ConfigurableSource interface
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *NFDConfig:
+		s.config = v
+	default:
+		panic(fmt.Sprintf("invalid config type: %T", conf))
+	}
+}
 
 // Discover features
-func (s Source) Discover() (source.Features, error) {
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
 	devs, err := detectPci()
@@ -61,7 +90,7 @@ func (s Source) Discover() (source.Features, error) {
 	// Construct a device label format, a sorted list of valid attributes
 	deviceLabelFields := []string{}
 	configLabelFields := map[string]bool{}
-	for _, field := range Config.DeviceLabelFields {
+	for _, field := range s.config.DeviceLabelFields {
 		configLabelFields[field] = true
 	}
 
@@ -85,7 +114,7 @@ func (s Source) Discover() (source.Features, error) {
 
 	// Iterate over all device classes
 	for class, classDevs := range devs {
-		for _, white := range Config.DeviceClassWhitelist {
+		for _, white := range s.config.DeviceClassWhitelist {
 			if strings.HasPrefix(class, strings.ToLower(white)) {
 				for _, dev := range classDevs {
 					devLabel := ""
@@ -96,7 +125,8 @@ func (s Source) Discover() (source.Features, error) {
 						}
 					}
 					devLabel += ".present"
-					features[devLabel] = true
+					source.Explainf("%s: device %v matches deviceClassWhitelist entry %q", devLabel, dev, white)
+					features[devLabel] = source.BoolValue(true)
 				}
 			}
 		}
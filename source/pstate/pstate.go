@@ -18,8 +18,6 @@ package pstate
 
 import (
 	"fmt"
-	"io/ioutil"
-	"runtime"
 
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
 )
@@ -27,6 +25,16 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Intel P-State driver features, e.g. turbo boost",
+		DefaultEnabled: true,
+		// On Arm platform, the frequency boost mechanism is software-based
+		Archs: []string{"!arm64"},
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "pstate" }
 
@@ -34,21 +42,17 @@ func (s Source) Name() string { return "pstate" }
 func (s Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
-	// On Arm platform, the frequency boost mechanism is software-based.
-	// So skip pstate detection on Arm.
-	switch runtime.GOARCH {
-	case "arm64":
-		return features, nil
-	}
-
 	// Only looking for turbo boost for now...
-	bytes, err := ioutil.ReadFile("/sys/devices/system/cpu/intel_pstate/no_turbo")
+	bytes, err := source.ReadFile("/sys/devices/system/cpu/intel_pstate/no_turbo")
 	if err != nil {
 		return nil, fmt.Errorf("can't detect whether turbo boost is enabled: %s", err.Error())
 	}
 	if bytes[0] == byte('0') {
 		// Turbo boost is enabled.
-		features["turbo"] = true
+		source.Explainf("turbo: no_turbo is 0, turbo boost enabled")
+		features["turbo"] = source.BoolValue(true)
+	} else {
+		source.Explainf("turbo: no_turbo is not 0, turbo boost disabled")
 	}
 
 	return features, nil
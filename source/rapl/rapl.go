@@ -38,6 +38,14 @@ const (
 // Implement FeatureSource interface
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Intel RAPL thermal spec power",
+		DefaultEnabled: true,
+	})
+}
+
 func (s Source) Name() string { return "rapl" }
 
 func (s Source) Discover() (source.Features, error) {
@@ -47,7 +55,8 @@ func (s Source) Discover() (source.Features, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Failed to detect thermal spec power: %v", err)
 	}
-	features["thermal-spec-power"] = p
+	source.Explainf("thermal-spec-power: %d W calculated from MSRs 0x%x and 0x%x", p, MSR_RAPL_POWER_UNIT, MSR_PKG_POWER_INFO)
+	features["thermal-spec-power"] = source.IntValue(p)
 
 	return features, nil
 }
@@ -71,7 +80,7 @@ func read_msr(msr int64) (uint64, error) {
 	if err != nil {
 		return 0, err
 	} else if n != 8 {
-		err = fmt.Errorf("short read on MSR 0x%x, %v of 8 bytes read", n)
+		err = fmt.Errorf("short read on MSR 0x%x, %v of 8 bytes read", msr, n)
 		return 0, err
 	}
 
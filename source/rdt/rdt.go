@@ -26,6 +26,14 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Intel Resource Director Technology",
+		DefaultEnabled: true,
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "rdt" }
 
@@ -35,50 +43,56 @@ func (s Source) Discover() (source.Features, error) {
 
 	cmd := exec.Command("bash", "-c", "mon-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTMON: mon-discovery failed: %v", err)
 		glog.Errorf("support for RDT monitoring was not detected: %v", err)
 	} else {
 		// RDT monitoring detected.
-		features["RDTMON"] = true
+		features["RDTMON"] = source.BoolValue(true)
 	}
 
 	cmd = exec.Command("bash", "-c", "mon-cmt-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTCMT: mon-cmt-discovery failed: %v", err)
 		glog.Errorf("support for RDT CMT monitoring was not detected: %v", err)
 	} else {
 		// RDT CMT monitoring detected.
-		features["RDTCMT"] = true
+		features["RDTCMT"] = source.BoolValue(true)
 	}
 
 	cmd = exec.Command("bash", "-c", "mon-mbm-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTMBM: mon-mbm-discovery failed: %v", err)
 		glog.Errorf("support for RDT MBM monitoring was not detected: %v", err)
 	} else {
 		// RDT MBM monitoring detected.
-		features["RDTMBM"] = true
+		features["RDTMBM"] = source.BoolValue(true)
 	}
 
 	cmd = exec.Command("bash", "-c", "l3-alloc-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTL3CA: l3-alloc-discovery failed: %v", err)
 		glog.Errorf("support for RDT L3 allocation was not detected: %v", err)
 	} else {
 		// RDT L3 cache allocation detected.
-		features["RDTL3CA"] = true
+		features["RDTL3CA"] = source.BoolValue(true)
 	}
 
 	cmd = exec.Command("bash", "-c", "l2-alloc-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTL2CA: l2-alloc-discovery failed: %v", err)
 		glog.Errorf("support for RDT L2 allocation was not detected: %v", err)
 	} else {
 		// RDT L2 cache allocation detected.
-		features["RDTL2CA"] = true
+		features["RDTL2CA"] = source.BoolValue(true)
 	}
 
 	cmd = exec.Command("bash", "-c", "mem-bandwidth-alloc-discovery")
 	if err := cmd.Run(); err != nil {
+		source.Explainf("RDTMBA: mem-bandwidth-alloc-discovery failed: %v", err)
 		glog.Errorf("support for RDT Memory bandwidth allocation was not detected: %v", err)
 	} else {
 		// RDT Memory bandwidth allocation detected.
-		features["RDTMBA"] = true
+		features["RDTMBA"] = source.BoolValue(true)
 	}
 
 	return features, nil
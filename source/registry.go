@@ -0,0 +1,141 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Registration describes a feature source known to NFD.
+type Registration struct {
+	// Source is the feature source itself.
+	Source FeatureSource
+
+	// Description is a short human-readable description of the source,
+	// shown in the help output.
+	Description string
+
+	// DefaultEnabled tells if the source is enabled when no --sources are
+	// specified on the command line.
+	DefaultEnabled bool
+
+	// Archs lists the architectures (in GOARCH notation) the source is
+	// supported on. Architectures prefixed with "!" are excluded, e.g.
+	// "!arm64" means all architectures but arm64. Empty means all
+	// architectures.
+	Archs []string
+}
+
+// Name returns the name of the registered source.
+func (r Registration) Name() string { return r.Source.Name() }
+
+// Supported tells if the source is supported on the current architecture.
+func (r Registration) Supported() bool {
+	included, excluded := r.archs()
+	for _, arch := range excluded {
+		if arch == runtime.GOARCH {
+			return false
+		}
+	}
+	if len(included) == 0 {
+		return true
+	}
+	for _, arch := range included {
+		if arch == runtime.GOARCH {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchsDescription returns a human-readable description of the architectures
+// the source is supported on, e.g. "only on amd64, 386" or "not on arm64"
+func (r Registration) ArchsDescription() string {
+	included, excluded := r.archs()
+	desc := []string{}
+	if len(included) > 0 {
+		desc = append(desc, "only on "+strings.Join(included, ", "))
+	}
+	if len(excluded) > 0 {
+		desc = append(desc, "not on "+strings.Join(excluded, ", "))
+	}
+	return strings.Join(desc, ", ")
+}
+
+// archs splits Archs into included and excluded architectures
+func (r Registration) archs() (included []string, excluded []string) {
+	for _, arch := range r.Archs {
+		if strings.HasPrefix(arch, "!") {
+			excluded = append(excluded, strings.TrimPrefix(arch, "!"))
+		} else {
+			included = append(included, arch)
+		}
+	}
+	return included, excluded
+}
+
+var registry = map[string]Registration{}
+
+// Register adds a feature source to the registry. It is supposed to be called
+// from the init() function of the package implementing the source, and,
+// panics if a source with the same name has already been registered.
+func Register(r Registration) {
+	if r.Source == nil {
+		panic("source: Register called with nil Source")
+	}
+	name := r.Name()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("source: Register called twice for source %q", name))
+	}
+	registry[name] = r
+}
+
+// Lookup returns the registration of the named source.
+func Lookup(name string) (Registration, bool) {
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Registrations returns all registered sources, sorted by name.
+func Registrations() []Registration {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	regs := make([]Registration, len(names))
+	for i, name := range names {
+		regs[i] = registry[name]
+	}
+	return regs
+}
+
+// DefaultSources returns the names of the sources that are enabled by
+// default on the current architecture, sorted by name.
+func DefaultSources() []string {
+	names := []string{}
+	for _, r := range Registrations() {
+		if r.DefaultEnabled && r.Supported() {
+			names = append(names, r.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistrationArchs(t *testing.T) {
+	Convey("When checking the architectures of a source", t, func() {
+		Convey("Sources without architectures are supported everywhere", func() {
+			So(Registration{}.Supported(), ShouldBeTrue)
+		})
+		Convey("Listed architectures are supported", func() {
+			r := Registration{Archs: []string{"foo", runtime.GOARCH}}
+			So(r.Supported(), ShouldBeTrue)
+			r = Registration{Archs: []string{"foo"}}
+			So(r.Supported(), ShouldBeFalse)
+			So(r.ArchsDescription(), ShouldEqual, "only on foo")
+		})
+		Convey("Excluded architectures are not supported", func() {
+			r := Registration{Archs: []string{"!" + runtime.GOARCH}}
+			So(r.Supported(), ShouldBeFalse)
+			r = Registration{Archs: []string{"!foo"}}
+			So(r.Supported(), ShouldBeTrue)
+			So(r.ArchsDescription(), ShouldEqual, "not on foo")
+		})
+	})
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package security
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+type Source struct{}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Linux security modules, kernel lockdown and integrity",
+		DefaultEnabled: true,
+	})
+}
+
+func (s Source) Name() string { return "security" }
+
+func (s Source) Discover() (source.Features, error) {
+	return discover(source.HostSysDir, source.HostEtcDir), nil
+}
+
+// EVM initialization flags of the securityfs evm file
+const (
+	evmInitHMAC = 0x1
+	evmInitX509 = 0x2
+)
+
+// Regexp for the selected lockdown mode, e.g. "none [integrity] confidentiality"
+var lockdownRe = regexp.MustCompile(`\[(\w+)\]`)
+
+// discover detects the security features of the host, with the sysfs and the
+// /etc directory of the host at sysDir and etcDir, respectively. Missing
+// files mean that the kernel does not support the feature, or, that it is
+// disabled.
+func discover(sysDir string, etcDir string) source.Features {
+	features := source.Features{}
+	securityfs := filepath.Join(sysDir, "kernel/security")
+
+	// Active LSMs, e.g. "lockdown,capability,yama,apparmor"
+	if raw, err := source.ReadFile(filepath.Join(securityfs, "lsm")); err == nil {
+		lsms := source.ListValue{}
+		for _, lsm := range strings.Split(strings.TrimSpace(string(raw)), ",") {
+			if lsm != "" {
+				lsms = append(lsms, source.StringValue(lsm))
+			}
+		}
+		if len(lsms) > 0 {
+			features["lsm"] = lsms
+		}
+	}
+
+	// AppArmor
+	if raw, err := source.ReadFile(filepath.Join(sysDir, "module/apparmor/parameters/enabled")); err == nil && bytes.HasPrefix(raw, []byte("Y")) {
+		features["apparmor.enabled"] = source.BoolValue(true)
+		// One "<name> (<mode>)" line per loaded profile
+		if raw, err := source.ReadFile(filepath.Join(securityfs, "apparmor/profiles")); err == nil {
+			profiles := 0
+			for _, line := range bytes.Split(raw, []byte("\n")) {
+				if len(bytes.TrimSpace(line)) > 0 {
+					profiles++
+				}
+			}
+			features["apparmor.profiles"] = source.IntValue(profiles)
+		}
+	}
+
+	// Kernel lockdown
+	if raw, err := source.ReadFile(filepath.Join(securityfs, "lockdown")); err == nil {
+		if m := lockdownRe.FindSubmatch(raw); m != nil {
+			features["lockdown"] = source.StringValue(m[1])
+		}
+	}
+
+	// IMA and EVM, found under integrity/ in recent kernels
+	for _, dir := range []string{securityfs, filepath.Join(securityfs, "integrity")} {
+		if _, err := os.Stat(filepath.Join(dir, "ima")); err == nil {
+			source.Explainf("ima.enabled: found %s", filepath.Join(dir, "ima"))
+			features["ima.enabled"] = source.BoolValue(true)
+		}
+		evmFile := filepath.Join(dir, "evm")
+		if fi, err := os.Stat(evmFile); err == nil && fi.IsDir() {
+			evmFile = filepath.Join(evmFile, "evm")
+		}
+		if raw, err := source.ReadFile(evmFile); err == nil {
+			if flags, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 32); err == nil && flags != 0 {
+				features["evm.enabled"] = source.BoolValue(true)
+				if flags&evmInitHMAC != 0 {
+					features["evm.hmac"] = source.BoolValue(true)
+				}
+				if flags&evmInitX509 != 0 {
+					features["evm.x509"] = source.BoolValue(true)
+				}
+			}
+		}
+	}
+
+	// SELinux
+	mode := "disabled"
+	if raw, err := source.ReadFile(filepath.Join(sysDir, "fs/selinux/enforce")); err == nil {
+		if bytes.HasPrefix(raw, []byte("1")) {
+			mode = "enforcing"
+		} else {
+			mode = "permissive"
+		}
+		if policy := selinuxPolicyType(filepath.Join(etcDir, "selinux/config")); policy != "" {
+			features["selinux.policy"] = source.StringValue(policy)
+		}
+	}
+	features["selinux.mode"] = source.StringValue(mode)
+
+	return features
+}
+
+// selinuxPolicyType returns the type of the SELinux policy, e.g. targeted,
+// configured in the SELinux config file
+func selinuxPolicyType(configFile string) string {
+	raw, err := source.ReadFile(configFile)
+	if err != nil {
+		return ""
+	}
+	policy := ""
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "SELINUXTYPE=") {
+			policy = strings.Trim(strings.TrimPrefix(line, "SELINUXTYPE="), `"'`)
+		}
+	}
+	return policy
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package security
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeFiles(dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		So(os.MkdirAll(filepath.Dir(path), 0755), ShouldBeNil)
+		So(ioutil.WriteFile(path, []byte(content), 0644), ShouldBeNil)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	Convey("When discovering security features", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-security-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		sysDir := filepath.Join(dir, "sys")
+		etcDir := filepath.Join(dir, "etc")
+
+		Convey("Nothing is detected on a host without security features", func() {
+			So(discover(sysDir, etcDir), ShouldResemble, source.Features{
+				"selinux.mode": source.StringValue("disabled"),
+			})
+		})
+
+		Convey("AppArmor, lockdown and integrity are detected", func() {
+			writeFiles(sysDir, map[string]string{
+				"kernel/security/lsm":                  "lockdown,capability,yama,apparmor,integrity",
+				"module/apparmor/parameters/enabled":   "Y\n",
+				"kernel/security/apparmor/profiles":    "docker-default (enforce)\n/usr/bin/man (enforce)\n",
+				"kernel/security/lockdown":             "none [integrity] confidentiality\n",
+				"kernel/security/integrity/ima/policy": "",
+				"kernel/security/integrity/evm/evm":    "2\n",
+			})
+
+			So(discover(sysDir, etcDir), ShouldResemble, source.Features{
+				"lsm": source.ListValue{
+					source.StringValue("lockdown"),
+					source.StringValue("capability"),
+					source.StringValue("yama"),
+					source.StringValue("apparmor"),
+					source.StringValue("integrity"),
+				},
+				"apparmor.enabled":  source.BoolValue(true),
+				"apparmor.profiles": source.IntValue(2),
+				"lockdown":          source.StringValue("integrity"),
+				"ima.enabled":       source.BoolValue(true),
+				"evm.enabled":       source.BoolValue(true),
+				"evm.x509":          source.BoolValue(true),
+				"selinux.mode":      source.StringValue("disabled"),
+			})
+		})
+
+		Convey("SELinux mode and policy type are detected", func() {
+			writeFiles(sysDir, map[string]string{"fs/selinux/enforce": "0"})
+			writeFiles(etcDir, map[string]string{"selinux/config": "# comment\nSELINUX=permissive\nSELINUXTYPE=targeted\n"})
+
+			So(discover(sysDir, etcDir), ShouldResemble, source.Features{
+				"selinux.mode":   source.StringValue("permissive"),
+				"selinux.policy": source.StringValue("targeted"),
+			})
+		})
+	})
+}
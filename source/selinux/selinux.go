@@ -18,24 +18,34 @@ package selinux
 
 import (
 	"fmt"
-	"io/ioutil"
 
 	"github.com/kubernetes-incubator/node-feature-discovery/source"
 )
 
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "SELinux status",
+		DefaultEnabled: true,
+	})
+}
+
 func (s Source) Name() string { return "selinux" }
 
 func (s Source) Discover() (source.Features, error) {
 	features := source.Features{}
-	status, err := ioutil.ReadFile("/host-sys/fs/selinux/enforce")
+	status, err := source.ReadFile("/host-sys/fs/selinux/enforce")
 	if err != nil {
 		return nil, fmt.Errorf("Failed to detect the status of selinux, please check if the system supports selinux and make sure /sys on the host is mounted into the container: %s", err.Error())
 	}
 	if status[0] == byte('1') {
 		// selinux is enabled.
-		features["enabled"] = true
+		source.Explainf("enabled: selinux is in enforcing mode")
+		features["enabled"] = source.BoolValue(true)
+	} else {
+		source.Explainf("enabled: selinux is not in enforcing mode")
 	}
 	return features, nil
 }
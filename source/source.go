@@ -16,20 +16,14 @@ limitations under the License.
 
 package source
 
-// Value of a feature
-type FeatureValue interface {
-}
-
-// Boolean feature value
-type BoolFeatureValue bool
-
-func (b BoolFeatureValue) String() string {
-	if b == true {
-		return "true"
-	}
-	return "false"
-}
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
+// Features maps feature names to their values. See values.go for the
+// available value types.
 type Features map[string]FeatureValue
 
 // FeatureSource represents a source of a discovered node feature.
@@ -40,3 +34,118 @@ type FeatureSource interface {
 	// Discover returns discovered features for this node.
 	Discover() (Features, error)
 }
+
+// Config is the configuration of one feature source.
+type Config interface{}
+
+// ConfigurableSource is implemented by feature sources that have
+// configuration options. The config of a source is decoded from the
+// sources.<name> section of the NFD config file and --options.
+type ConfigurableSource interface {
+	FeatureSource
+
+	// NewConfig returns a new config of the source, populated with the
+	// default values.
+	NewConfig() Config
+
+	// GetConfig returns the current config of the source.
+	GetConfig() Config
+
+	// SetConfig replaces the config of the source.
+	SetConfig(Config)
+}
+
+// ConfigValidator is implemented by configs that can check their own
+// validity after having been decoded.
+type ConfigValidator interface {
+	Validate() error
+}
+
+// SourceGroup is implemented by feature sources that stand for a dynamic set
+// of feature sources, e.g. external feature source daemons. The members of
+// the group are re-enumerated on every discovery round and each of them is
+// labeled under its own name.
+type SourceGroup interface {
+	// Sources returns the current members of the group.
+	Sources() ([]FeatureSource, error)
+}
+
+// FeatureConsumer is implemented by feature sources that make use of the
+// features discovered by other sources. Such sources are discovered after
+// all other sources.
+type FeatureConsumer interface {
+	FeatureSource
+
+	// SetDiscoveredFeatures is called before Discover() with the features
+	// discovered by the other sources in the current discovery round, keyed
+	// by source name.
+	SetDiscoveredFeatures(map[string]Features)
+}
+
+// NodeUpdates are changes to the node object, other than labels, requested
+// by a feature source. Names are relative to the source, similarly to feature
+// names.
+type NodeUpdates struct {
+	// Annotations maps annotation names to their values
+	Annotations map[string]string
+
+	// ExtendedResources maps extended resource names to their capacities
+	ExtendedResources map[string]int64
+}
+
+// NodeUpdater is implemented by feature sources that may request node
+// updates other than labels.
+type NodeUpdater interface {
+	FeatureSource
+
+	// NodeUpdates returns the updates requested in the most recent
+	// successful call to Discover().
+	NodeUpdates() NodeUpdates
+}
+
+// Paths of the host directories mounted into the NFD container
+const (
+	HostBootDir = "/host-boot"
+	HostEtcDir  = "/host-etc"
+	HostSysDir  = "/host-sys"
+	// Kernel modules, i.e. /lib/modules of the host
+	HostLibModulesDir = "/host-lib/modules"
+	// Kernel modules of usr-merged and ostree based hosts, i.e.
+	// /usr/lib/modules of the host
+	HostUsrLibModulesDir = "/host-usr/lib/modules"
+	// Procfs is not mounted separately, as the system-wide entries of the
+	// proc filesystem of the container, e.g. /proc/cpuinfo or /proc/pressure,
+	// are those of the host. Per-process and per-namespace entries, e.g.
+	// /proc/self/mountinfo, describe the container instead.
+	HostProcDir = "/proc"
+)
+
+// NFDVersion is the version of NFD, set by main. Sources may pass it on,
+// e.g. to hooks.
+var NFDVersion string
+
+// NodeName is the name of the node NFD is running on, set by main
+var NodeName string
+
+// Duration is a time.Duration that is (un)marshalled as a string such as
+// "1m30s" in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: %v", data, err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
@@ -26,6 +26,14 @@ import (
 // Source implements FeatureSource.
 type Source struct{}
 
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Non-rotational storage devices",
+		DefaultEnabled: true,
+	})
+}
+
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "storage" }
 
@@ -38,13 +46,14 @@ func (s Source) Discover() (source.Features, error) {
 	if err == nil {
 		for _, bdev := range blockdevices {
 			fname := "/sys/block/" + bdev.Name() + "/queue/rotational"
-			bytes, err := ioutil.ReadFile(fname)
+			bytes, err := source.ReadFile(fname)
 			if err != nil {
 				return nil, fmt.Errorf("can't read rotational status: %s", err.Error())
 			}
 			if bytes[0] == byte('0') {
 				// Non-rotational storage is present, add label.
-				features["nonrotationaldisk"] = true
+				source.Explainf("nonrotationaldisk: %s is non-rotational", bdev.Name())
+				features["nonrotationaldisk"] = source.BoolValue(true)
 				break
 			}
 		}
@@ -0,0 +1,168 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syscalls
+
+import (
+	"strconv"
+	"syscall"
+	"unsafe"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+const (
+	ioUringRegisterProbe = 8
+	ioUringOpSupported   = 1 << 0
+)
+
+// ioUringParams is struct io_uring_params, filled in by io_uring_setup()
+type ioUringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        [10]uint32
+	cqOff        [10]uint32
+}
+
+// ioUringProbe is struct io_uring_probe, with room for all opcodes
+type ioUringProbe struct {
+	lastOp uint8
+	opsLen uint8
+	resv   uint16
+	resv2  [3]uint32
+	ops    [256]ioUringProbeOp
+}
+
+type ioUringProbeOp struct {
+	op    uint8
+	resv  uint8
+	flags uint16
+	resv2 uint32
+}
+
+// Names of io_uring opcodes, indexed by enum io_uring_op
+var ioUringOps = []string{
+	"nop",
+	"readv",
+	"writev",
+	"fsync",
+	"read_fixed",
+	"write_fixed",
+	"poll_add",
+	"poll_remove",
+	"sync_file_range",
+	"sendmsg",
+	"recvmsg",
+	"timeout",
+	"timeout_remove",
+	"accept",
+	"async_cancel",
+	"link_timeout",
+	"connect",
+	"fallocate",
+	"openat",
+	"close",
+	"files_update",
+	"statx",
+	"read",
+	"write",
+	"fadvise",
+	"madvise",
+	"send",
+	"recv",
+	"openat2",
+	"epoll_ctl",
+	"splice",
+	"provide_buffers",
+	"remove_buffers",
+	"tee",
+	"shutdown",
+	"renameat",
+	"unlinkat",
+	"mkdirat",
+	"symlinkat",
+	"linkat",
+	"msg_ring",
+	"fsetxattr",
+	"setxattr",
+	"fgetxattr",
+	"getxattr",
+	"socket",
+	"uring_cmd",
+	"send_zc",
+	"sendmsg_zc",
+	"read_multishot",
+	"waitid",
+	"futex_wait",
+	"futex_wake",
+	"futex_waitv",
+	"fixed_fd_install",
+	"ftruncate",
+	"bind",
+	"listen",
+	"recv_zc",
+	"epoll_wait",
+	"readv_fixed",
+	"writev_fixed",
+	"pipe",
+}
+
+// ioUringSetup creates an io_uring instance with a single entry. Creating
+// an instance fails with EPERM if io_uring is disabled with the
+// kernel.io_uring_disabled sysctl.
+func ioUringSetup() (int, error) {
+	params := &ioUringParams{}
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, 1, uintptr(unsafe.Pointer(params)), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// ioUringProbeOps returns the opcodes supported by the io_uring instance fd
+func ioUringProbeOps(fd int) (source.ListValue, error) {
+	probe := &ioUringProbe{}
+	_, _, errno := syscall.Syscall6(sysIoUringRegister, uintptr(fd), ioUringRegisterProbe,
+		uintptr(unsafe.Pointer(probe)), uintptr(len(probe.ops)), 0, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	return supportedOps(probe), nil
+}
+
+// supportedOps returns the names of the opcodes supported according to probe.
+// Opcodes added by later kernels are published by their number.
+func supportedOps(probe *ioUringProbe) source.ListValue {
+	ops := source.ListValue{}
+	for i := 0; i < int(probe.opsLen) && i < len(probe.ops); i++ {
+		op := probe.ops[i]
+		if op.flags&ioUringOpSupported == 0 {
+			continue
+		}
+		name := "op" + strconv.Itoa(int(op.op))
+		if int(op.op) < len(ioUringOps) {
+			name = ioUringOps[op.op]
+		}
+		ops = append(ops, source.StringValue(name))
+	}
+	return ops
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syscalls
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+)
+
+type Source struct{}
+
+func init() {
+	source.Register(source.Registration{
+		Source:         Source{},
+		Description:    "Availability of io_uring and other modern system calls",
+		DefaultEnabled: true,
+		// Numbers of the system calls are only known on these
+		Archs: []string{"amd64", "arm64"},
+	})
+}
+
+func (s Source) Name() string { return "syscalls" }
+
+func (s Source) Discover() (source.Features, error) {
+	features := source.Features{}
+
+	if fd, err := probe("io_uring", ioUringSetup); err == nil {
+		features["io_uring"] = source.BoolValue(true)
+		if ops, err := ioUringProbeOps(fd); err == nil {
+			features["io_uring.op"] = ops
+		} else {
+			// IORING_REGISTER_PROBE was added in Linux 5.6
+			source.Explainf("io_uring.op: %v", err)
+		}
+		syscall.Close(fd)
+	}
+	for name, fn := range map[string]func() (int, error){
+		"memfd_secret": memfdSecret,
+		"pidfd":        pidfdOpen,
+		"userfaultfd":  userfaultfd,
+	} {
+		if fd, err := probe(name, fn); err == nil {
+			features[name] = source.BoolValue(true)
+			syscall.Close(fd)
+		}
+	}
+
+	return features, nil
+}
+
+// probe calls a system call returning a file descriptor. The system call is
+// not available if it is not implemented by the kernel (ENOSYS), or, if it
+// is denied by seccomp or a sysctl (EPERM).
+func probe(name string, fn func() (int, error)) (int, error) {
+	fd, err := fn()
+	if err != nil {
+		source.Explainf("%s: %v", name, err)
+	} else {
+		source.Explainf("%s: got file descriptor %d", name, fd)
+	}
+	return fd, err
+}
+
+func fdSyscall(trap, a1, a2, a3 uintptr) (int, error) {
+	fd, _, errno := syscall.Syscall(trap, a1, a2, a3)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// Flags of the probed system calls
+const (
+	// Only handle page faults in user space, allowed for unprivileged
+	// users since Linux 5.11
+	uffdUserModeOnly = 1
+)
+
+func memfdSecret() (int, error) {
+	return fdSyscall(sysMemfdSecret, syscall.O_CLOEXEC, 0, 0)
+}
+
+func pidfdOpen() (int, error) {
+	return fdSyscall(sysPidfdOpen, uintptr(os.Getpid()), 0, 0)
+}
+
+func userfaultfd() (int, error) {
+	fd, err := fdSyscall(sysUserfaultfd, syscall.O_CLOEXEC|uffdUserModeOnly, 0, 0)
+	if err == syscall.EINVAL {
+		// Kernels before 5.11 do not know the flag
+		fd, err = fdSyscall(sysUserfaultfd, syscall.O_CLOEXEC, 0, 0)
+	}
+	return fd, err
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syscalls
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/kubernetes-incubator/node-feature-discovery/source"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIoUring(t *testing.T) {
+	Convey("The io_uring structure layouts match the kernel", t, func() {
+		So(unsafe.Sizeof(ioUringParams{}), ShouldEqual, 120)
+		So(unsafe.Offsetof(ioUringProbe{}.ops), ShouldEqual, 16)
+		So(unsafe.Sizeof(ioUringProbeOp{}), ShouldEqual, 8)
+	})
+
+	Convey("Supported opcodes are decoded from the probe", t, func() {
+		probe := &ioUringProbe{lastOp: 255, opsLen: 4}
+		probe.ops[0] = ioUringProbeOp{op: 0, flags: ioUringOpSupported}
+		probe.ops[1] = ioUringProbeOp{op: 1}
+		probe.ops[2] = ioUringProbeOp{op: 2, flags: ioUringOpSupported}
+		probe.ops[3] = ioUringProbeOp{op: 255, flags: ioUringOpSupported}
+		So(supportedOps(probe), ShouldResemble, source.ListValue{
+			source.StringValue("nop"),
+			source.StringValue("writev"),
+			source.StringValue("op255"),
+		})
+	})
+}
@@ -0,0 +1,30 @@
+//go:build !amd64 && !arm64
+// +build !amd64,!arm64
+
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syscalls
+
+// The numbers of the system calls are not known on other architectures, on
+// which the source is not supported. These only make the package compile.
+const (
+	sysIoUringSetup    = 0
+	sysIoUringRegister = 0
+	sysMemfdSecret     = 0
+	sysPidfdOpen       = 0
+	sysUserfaultfd     = 0
+)
@@ -0,0 +1,274 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type of a feature value
+type ValueType int
+
+const (
+	BoolType ValueType = iota
+	IntType
+	StringType
+	VersionType
+	ListType
+	MapType
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case BoolType:
+		return "bool"
+	case IntType:
+		return "int"
+	case StringType:
+		return "string"
+	case VersionType:
+		return "version"
+	case ListType:
+		return "list"
+	case MapType:
+		return "map"
+	}
+	return fmt.Sprintf("ValueType(%d)", int(t))
+}
+
+// FeatureValue is the value of a discovered feature.
+type FeatureValue interface {
+	// Type returns the type of the value.
+	Type() ValueType
+
+	// String returns the value in the form used in node labels. For lists
+	// and maps, which are published as multiple labels (see FeatureLabels),
+	// this is a human-readable representation only.
+	String() string
+}
+
+// BoolValue is a boolean feature value
+type BoolValue bool
+
+func (v BoolValue) Type() ValueType { return BoolType }
+
+func (v BoolValue) String() string { return strconv.FormatBool(bool(v)) }
+
+// IntValue is an integer feature value
+type IntValue int64
+
+func (v IntValue) Type() ValueType { return IntType }
+
+func (v IntValue) String() string { return strconv.FormatInt(int64(v), 10) }
+
+// StringValue is a free-form string feature value
+type StringValue string
+
+func (v StringValue) Type() ValueType { return StringType }
+
+func (v StringValue) String() string { return string(v) }
+
+// VersionValue is a semantic version feature value, e.g. 1.2.3 or 1.2.3-rc1
+type VersionValue struct {
+	Major int
+	Minor int
+	Patch int
+	// Pre is the pre-release part of the version, without the leading '-'
+	Pre string
+}
+
+var versionRe = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// ParseVersion parses a version string in the form of
+// [v]MAJOR[.MINOR[.PATCH]][-PRE][+BUILD]. Missing components are zero and
+// build metadata is ignored.
+func ParseVersion(s string) (VersionValue, error) {
+	m := versionRe.FindStringSubmatch(s)
+	if m == nil {
+		return VersionValue{}, fmt.Errorf("invalid version %q", s)
+	}
+	v := VersionValue{Pre: m[4]}
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return VersionValue{}, fmt.Errorf("invalid version %q: %v", s, err)
+		}
+		*p = n
+	}
+	return v, nil
+}
+
+func (v VersionValue) Type() ValueType { return VersionType }
+
+func (v VersionValue) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// MarshalJSON encodes the version as a string, in the same form as String()
+func (v VersionValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o,
+// respectively. A version with a pre-release part is less than the same
+// version without one, and, pre-release parts are compared lexically.
+func (v VersionValue) Compare(o VersionValue) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return strings.Compare(v.Pre, o.Pre)
+}
+
+// ListValue is a set of values. It is published as one boolean label per
+// element.
+type ListValue []FeatureValue
+
+func (v ListValue) Type() ValueType { return ListType }
+
+func (v ListValue) String() string {
+	elems := make([]string, len(v))
+	for i, e := range v {
+		elems[i] = e.String()
+	}
+	return strings.Join(elems, ",")
+}
+
+// MapValue is a set of named values. It is published as one label per
+// element.
+type MapValue map[string]FeatureValue
+
+func (v MapValue) Type() ValueType { return MapType }
+
+func (v MapValue) String() string {
+	elems := make([]string, 0, len(v))
+	for k, e := range v {
+		elems = append(elems, k+"="+e.String())
+	}
+	sort.Strings(elems)
+	return strings.Join(elems, ",")
+}
+
+// NewValue converts an untyped value, e.g. decoded from JSON, into a feature
+// value. Booleans, integers and strings are converted into the corresponding
+// value types, whereas floats and json.Numbers become integers if they have
+// an integral value and strings otherwise. Slices and maps are converted
+// recursively.
+func NewValue(value interface{}) (FeatureValue, error) {
+	switch v := value.(type) {
+	case FeatureValue:
+		return v, nil
+	case bool:
+		return BoolValue(v), nil
+	case int:
+		return IntValue(v), nil
+	case int32:
+		return IntValue(v), nil
+	case int64:
+		return IntValue(v), nil
+	case uint32:
+		return IntValue(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return StringValue(strconv.FormatUint(v, 10)), nil
+		}
+		return IntValue(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return IntValue(v), nil
+		}
+		return StringValue(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return IntValue(i), nil
+		}
+		return StringValue(v.String()), nil
+	case string:
+		return StringValue(v), nil
+	case []interface{}:
+		list := make(ListValue, len(v))
+		for i, e := range v {
+			elem, err := NewValue(e)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(MapValue, len(v))
+		for k, e := range v {
+			elem, err := NewValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = elem
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported feature value type %T", value)
+}
+
+// FeatureLabels returns the labels representing one feature, as a map of
+// label name suffixes to label values. Scalar values produce one label named
+// after the feature. Lists produce one "<name>.<element>" label per element,
+// with value "true". Maps produce one "<name>.<key>" label per element,
+// nested maps and lists being expanded recursively.
+func FeatureLabels(name string, value FeatureValue) map[string]string {
+	labels := map[string]string{}
+	addFeatureLabels(labels, name, value)
+	return labels
+}
+
+func addFeatureLabels(labels map[string]string, name string, value FeatureValue) {
+	switch v := value.(type) {
+	case ListValue:
+		for _, e := range v {
+			labels[name+"."+e.String()] = "true"
+		}
+	case MapValue:
+		for k, e := range v {
+			addFeatureLabels(labels, name+"."+k, e)
+		}
+	default:
+		labels[name] = value.String()
+	}
+}
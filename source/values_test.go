@@ -0,0 +1,96 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeatureLabels(t *testing.T) {
+	Convey("When serializing feature values into labels", t, func() {
+		Convey("Scalar values produce one label", func() {
+			So(FeatureLabels("a", BoolValue(true)), ShouldResemble, map[string]string{"a": "true"})
+			So(FeatureLabels("a", IntValue(-42)), ShouldResemble, map[string]string{"a": "-42"})
+			So(FeatureLabels("a", StringValue("foo")), ShouldResemble, map[string]string{"a": "foo"})
+			So(FeatureLabels("a", VersionValue{Major: 4, Minor: 19}), ShouldResemble, map[string]string{"a": "4.19.0"})
+		})
+
+		Convey("Lists and maps are expanded", func() {
+			value := MapValue{
+				"list": ListValue{StringValue("x"), StringValue("y")},
+				"int":  IntValue(1),
+				"map":  MapValue{"b": BoolValue(false)},
+			}
+			So(FeatureLabels("a", value), ShouldResemble, map[string]string{
+				"a.list.x": "true",
+				"a.list.y": "true",
+				"a.int":    "1",
+				"a.map.b":  "false",
+			})
+		})
+	})
+}
+
+func TestNewValue(t *testing.T) {
+	Convey("When converting untyped values", t, func() {
+		var raw map[string]interface{}
+		err := json.Unmarshal([]byte(`{"b": true, "i": 3, "f": 1.5, "s": "x", "l": ["y"], "m": {"z": 1}}`), &raw)
+		So(err, ShouldBeNil)
+
+		v, err := NewValue(raw)
+		Convey("Values get the corresponding types", func() {
+			So(err, ShouldBeNil)
+			So(v, ShouldResemble, MapValue{
+				"b": BoolValue(true),
+				"i": IntValue(3),
+				"f": StringValue("1.5"),
+				"s": StringValue("x"),
+				"l": ListValue{StringValue("y")},
+				"m": MapValue{"z": IntValue(1)},
+			})
+		})
+
+		Convey("Unsupported types produce an error", func() {
+			_, err := NewValue(struct{}{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestVersionValue(t *testing.T) {
+	Convey("When parsing and comparing versions", t, func() {
+		v, err := ParseVersion("v4.19.2-rc1+build5")
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, VersionValue{Major: 4, Minor: 19, Patch: 2, Pre: "rc1"})
+
+		_, err = ParseVersion("4.x")
+		So(err, ShouldNotBeNil)
+
+		parse := func(s string) VersionValue {
+			v, err := ParseVersion(s)
+			So(err, ShouldBeNil)
+			return v
+		}
+		So(parse("4.19").Compare(parse("4.19.0")), ShouldEqual, 0)
+		So(parse("4.9").Compare(parse("4.19")), ShouldEqual, -1)
+		So(parse("5").Compare(parse("4.19.100")), ShouldEqual, 1)
+		So(parse("4.19-rc1").Compare(parse("4.19")), ShouldEqual, -1)
+	})
+}